@@ -1,7 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"expvar"
+	"flag"
 	"log"
+	"net/http"
+	"os/signal"
+	"sync"
+	"syscall"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/shakareem/gigoseek/pkg/concerts"
@@ -11,33 +19,121 @@ import (
 )
 
 func main() {
-	storage, err := storage.NewPostgresStorage()
+	migrateOnly := flag.Bool("migrate", false, "apply database migrations and exit")
+	rollback := flag.Int("rollback", 0, "roll back the given number of latest migrations and exit")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// миграции применяются при открытии хранилища, кроме отката: он не должен сначала применять новые
+	storage, err := openStorage(ctx, *rollback == 0)
 	if err != nil {
 		log.Fatal("Failed to open database:", err)
 	}
 
+	if *rollback > 0 {
+		if err := storage.Rollback(ctx, *rollback); err != nil {
+			log.Fatal("Failed to roll back migrations:", err)
+		}
+	}
+	if *migrateOnly || *rollback > 0 {
+		storage.Close()
+		log.Println("Migrations done")
+		return
+	}
+
 	cfg := config.Get()
 	if cfg.TelegramApiToken == "" || cfg.SpotifyClientID == "" || cfg.SpotifyClientSecret == "" {
 		log.Fatal("Required private config values not found in configs/private.json")
 	}
+	if cfg.Telegram.UpdatesMode == telegram.UpdatesModeWebhook && cfg.TelegramWebhookSecret == "" {
+		log.Fatal("TELEGRAM_WEBHOOK_SECRET is required in webhook mode")
+	}
 
 	botAPI, err := tgbotapi.NewBotAPI(cfg.TelegramApiToken)
 	if err != nil {
 		log.Fatal("Failed to create bot:", err)
 	}
 
-	authUpdates := make(chan int64, 100)
+	authUpdates := make(chan telegram.AuthResult, 100)
+
+	concertsProvider := &concerts.TimepadConcertProvider{}
+
+	bot := telegram.NewBot(botAPI, storage, concertsProvider, authUpdates)
 
-	authServer := telegram.NewAuthServer(storage, authUpdates)
+	authServer := telegram.NewAuthServer(storage, concertsProvider, authUpdates)
+	if cfg.Telegram.UpdatesMode == telegram.UpdatesModeWebhook {
+		authServer.Handle(cfg.Telegram.WebhookPath, bot.WebhookHandler())
+	}
+
+	var servers sync.WaitGroup
+	servers.Add(1)
 	go func() {
-		if err := authServer.Run(); err != nil {
-			log.Fatal("Failed to start auth server:", err)
+		defer servers.Done()
+		if err := authServer.Run(ctx); err != nil {
+			log.Println("Auth server failed:", err)
+			stop()
 		}
 	}()
 
-	concertsProvider := &concerts.TimepadConcertProvider{}
+	if cfg.DebugAddr != "" {
+		servers.Add(1)
+		go func() {
+			defer servers.Done()
+			runDebugServer(ctx, cfg.DebugAddr)
+		}()
+	}
 
-	bot := telegram.NewBot(botAPI, storage, concertsProvider, authUpdates)
+	if err := bot.Start(ctx); err != nil {
+		log.Println("Bot failed:", err)
+		stop()
+	}
+
+	servers.Wait()
 
-	bot.Start()
+	if err := storage.Close(); err != nil {
+		log.Println("Failed to close database:", err)
+	}
+
+	log.Println("Shutdown complete")
+}
+
+// database хранилище бота вместе с управлением схемой
+type database interface {
+	telegram.Storage
+	Rollback(ctx context.Context, steps int) error
+	Close() error
+}
+
+func openStorage(ctx context.Context, migrate bool) (database, error) {
+	driver, err := storage.ConfiguredDriver()
+	if err != nil {
+		return nil, err
+	}
+
+	if driver == storage.DriverSQLite {
+		return storage.NewSQLiteStorage(ctx, migrate)
+	}
+	return storage.NewPostgresStorage(ctx, migrate)
+}
+
+// runDebugServer отдаёт expvar со статистикой диспетчера на /debug/vars. Слушает отдельный адрес,
+// чтобы метрики не были видны снаружи вместе с /callback
+func runDebugServer(ctx context.Context, addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	server := &http.Server{Addr: addr, Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+		if err := server.Close(); err != nil {
+			log.Println("Failed to stop debug server:", err)
+		}
+	}()
+
+	log.Printf("Starting debug server on %s", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Println("Debug server failed:", err)
+	}
 }
@@ -0,0 +1,47 @@
+// reencrypt перешифровывает сохранённые токены Spotify первым ключом из TOKEN_ENCRYPTION_KEYS.
+// Запускается после ротации ключа, старый ключ при этом должен оставаться в списке
+package main
+
+import (
+	"context"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/shakareem/gigoseek/pkg/storage"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	storage, err := openStorage(ctx)
+	if err != nil {
+		log.Fatal("Failed to open database:", err)
+	}
+	defer storage.Close()
+
+	updated, err := storage.ReencryptTokens(ctx)
+	if err != nil {
+		log.Fatal("Failed to re-encrypt tokens:", err)
+	}
+
+	log.Printf("Re-encrypted %d tokens", updated)
+}
+
+type database interface {
+	ReencryptTokens(ctx context.Context) (int, error)
+	Close() error
+}
+
+func openStorage(ctx context.Context) (database, error) {
+	driver, err := storage.ConfiguredDriver()
+	if err != nil {
+		return nil, err
+	}
+
+	if driver == storage.DriverSQLite {
+		return storage.NewSQLiteStorage(ctx, true)
+	}
+	return storage.NewPostgresStorage(ctx, true)
+}
@@ -1,6 +1,15 @@
 package concerts
 
+import (
+	"strings"
+	"time"
+)
+
+// timepadTimeFormat формат времени начала события в ответах timepad
+const timepadTimeFormat = "2006-01-02T15:04:05-0700"
+
 type Concert struct {
+	ID          int
 	Name        string
 	Description string
 	StartsAt    string
@@ -8,3 +17,17 @@ type Concert struct {
 	Address     string
 	URL         string
 }
+
+func (c Concert) StartTime() (time.Time, error) {
+	return time.Parse(timepadTimeFormat, c.StartsAt)
+}
+
+func (c Concert) Location() string {
+	parts := make([]string, 0, 2)
+	for _, part := range []string{c.City, c.Address} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
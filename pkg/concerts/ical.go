@@ -0,0 +1,92 @@
+package concerts
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	icalTimeFormat = "20060102T150405Z"
+	icalLineLimit  = 75
+	icalProductID  = "-//gigoseek//concerts//RU"
+)
+
+// defaultDuration используется как длительность события, так как timepad не отдаёт время окончания
+const defaultDuration = 3 * time.Hour
+
+// ICalendar строит документ в формате iCalendar (RFC 5545) со всеми переданными концертами
+func ICalendar(name string, concerts []Concert) []byte {
+	var b strings.Builder
+
+	writeLine(&b, "BEGIN:VCALENDAR")
+	writeLine(&b, "VERSION:2.0")
+	writeLine(&b, "PRODID:"+icalProductID)
+	writeLine(&b, "CALSCALE:GREGORIAN")
+	writeLine(&b, "METHOD:PUBLISH")
+	if name != "" {
+		writeLine(&b, "X-WR-CALNAME:"+escapeText(name))
+	}
+
+	stamp := time.Now().UTC().Format(icalTimeFormat)
+	for _, c := range concerts {
+		startsAt, err := c.StartTime()
+		if err != nil {
+			continue
+		}
+
+		writeLine(&b, "BEGIN:VEVENT")
+		writeLine(&b, fmt.Sprintf("UID:timepad-%d@gigoseek", c.ID))
+		writeLine(&b, "DTSTAMP:"+stamp)
+		writeLine(&b, "DTSTART:"+startsAt.UTC().Format(icalTimeFormat))
+		writeLine(&b, "DTEND:"+startsAt.Add(defaultDuration).UTC().Format(icalTimeFormat))
+		writeLine(&b, "SUMMARY:"+escapeText(c.Name))
+		if c.Description != "" {
+			writeLine(&b, "DESCRIPTION:"+escapeText(c.Description))
+		}
+		if location := c.Location(); location != "" {
+			writeLine(&b, "LOCATION:"+escapeText(location))
+		}
+		if c.URL != "" {
+			writeLine(&b, "URL:"+c.URL)
+		}
+		writeLine(&b, "END:VEVENT")
+	}
+
+	writeLine(&b, "END:VCALENDAR")
+
+	return []byte(b.String())
+}
+
+func escapeText(s string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	)
+	return replacer.Replace(s)
+}
+
+// writeLine записывает строку, перенося её по 75 байт без разрыва utf-8 символов
+func writeLine(b *strings.Builder, line string) {
+	limit := icalLineLimit
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// строка продолжения начинается с пробела, он тоже входит в лимит
+		limit = icalLineLimit - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+func isRuneStart(c byte) bool {
+	return c&0xC0 != 0x80
+}
@@ -0,0 +1,72 @@
+package concerts
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Концерт", "Концерт"},
+		{`a\b`, `a\\b`},
+		{"Москва, Тверская; 1", `Москва\, Тверская\; 1`},
+		{"line1\nline2", `line1\nline2`},
+		{"line1\r\nline2", `line1\nline2`},
+	}
+
+	for _, tt := range tests {
+		if got := escapeText(tt.in); got != tt.want {
+			t.Errorf("escapeText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWriteLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want []string
+	}{
+		{"short", "SUMMARY:Концерт", []string{"SUMMARY:Концерт"}},
+		{"exactly 75", strings.Repeat("a", 75), []string{strings.Repeat("a", 75)}},
+		{"ascii", strings.Repeat("a", 160), []string{
+			strings.Repeat("a", 75),
+			" " + strings.Repeat("a", 74),
+			" " + strings.Repeat("a", 11),
+		}},
+		// 2 байта на символ: 75-й байт пришёлся бы на середину символа
+		{"cyrillic", "S:" + strings.Repeat("я", 40), []string{
+			"S:" + strings.Repeat("я", 36),
+			" " + strings.Repeat("я", 4),
+		}},
+		// 4 байта на символ
+		{"emoji", "S:" + strings.Repeat("🎸", 20), []string{
+			"S:" + strings.Repeat("🎸", 18),
+			" " + strings.Repeat("🎸", 2),
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			writeLine(&b, tt.line)
+
+			got := strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n")
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Fatalf("writeLine folded into %q, want %q", got, tt.want)
+			}
+
+			for _, line := range got {
+				if len(line) > icalLineLimit {
+					t.Errorf("line %q is %d octets long", line, len(line))
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("line %q splits a utf-8 character", line)
+				}
+			}
+		})
+	}
+}
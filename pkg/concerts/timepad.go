@@ -1,11 +1,13 @@
 package concerts
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/shakareem/gigoseek/pkg/config"
 )
@@ -27,23 +29,65 @@ type EventsResponse struct {
 	Total  int     `json:"total"`
 }
 
+// StatusError Timepad ответил кодом, отличным от 200
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return "bad response: " + e.Status
+}
+
 type TimepadConcertProvider struct{}
 
-func (p *TimepadConcertProvider) GetConcerts(artists []string, city string) []Concert {
+// GetConcerts ищет концерты каждого артиста. Событие, найденное по нескольким артистам (фестиваль,
+// совместный концерт), возвращается один раз. Если часть запросов не удалась, возвращает то,
+// что нашлось; ошибка возвращается, только когда не удался ни один запрос
+func (p *TimepadConcertProvider) GetConcerts(ctx context.Context, artists []string, city string) ([]Concert, error) {
 	concerts := []Concert{}
+	seen := map[int]bool{}
+	var lastErr error
+	failed := 0
 	for _, artist := range artists {
-		artistConcerts, err := getArtistConcert(artist, city)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		artistConcerts, err := getArtistConcert(ctx, artist, city)
 		if err != nil {
 			log.Printf("Ошибка при получении событий для артиста %s: %v", artist, err)
+			lastErr = err
+			failed++
 			continue
 		}
-		concerts = append(concerts, artistConcerts...)
+		for _, c := range artistConcerts {
+			if !seen[c.ID] {
+				seen[c.ID] = true
+				concerts = append(concerts, c)
+			}
+		}
+	}
+
+	if failed > 0 && failed == len(artists) {
+		return nil, fmt.Errorf("failed to get concerts for all %d artists: %w", failed, lastErr)
 	}
 
-	return concerts
+	return concerts, nil
 }
 
-func getArtistConcert(artist, city string) ([]Concert, error) {
+func (p *TimepadConcertProvider) GetConcert(ctx context.Context, id int) (Concert, error) {
+	eventURL := fmt.Sprintf("%s/%d.json", strings.TrimSuffix(config.Get().Timepad.ApiURL, ".json"), id)
+
+	var event Event
+	if err := getTimepad(ctx, eventURL, &event); err != nil {
+		return Concert{}, err
+	}
+
+	return event.toConcert(), nil
+}
+
+func getArtistConcert(ctx context.Context, artist, city string) ([]Concert, error) {
 	params := url.Values{}
 	params.Add("category_ids", config.Get().Timepad.ConcertsCategoryID)
 	params.Add("cities", city)
@@ -51,39 +95,48 @@ func getArtistConcert(artist, city string) ([]Concert, error) {
 
 	fullURL := config.Get().Timepad.ApiURL + "?" + params.Encode()
 
-	req, err := http.NewRequest("GET", fullURL, nil)
-	if err != nil {
+	var result EventsResponse
+	if err := getTimepad(ctx, fullURL, &result); err != nil {
 		return nil, err
 	}
 
+	concerts := make([]Concert, len(result.Values))
+	for i, e := range result.Values {
+		concerts[i] = e.toConcert()
+	}
+
+	return concerts, nil
+}
+
+func getTimepad(ctx context.Context, fullURL string, result any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
+	if err != nil {
+		return err
+	}
+
 	req.Header.Set("Authorization", "Bearer "+config.Get().TimepadApiToken)
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad response: %s", resp.Status)
+		return &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
 	}
 
-	var result EventsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
-	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
 
-	concerts := make([]Concert, len(result.Values))
-	for i, c := range result.Values {
-		concerts[i] = Concert{
-			Name:        c.Name,
-			Description: c.Description,
-			StartsAt:    c.StartsAt,
-			City:        c.Location.City,
-			Address:     c.Location.Address,
-			URL:         c.URL,
-		}
+func (e Event) toConcert() Concert {
+	return Concert{
+		ID:          e.ID,
+		Name:        e.Name,
+		Description: e.Description,
+		StartsAt:    e.StartsAt,
+		City:        e.Location.City,
+		Address:     e.Location.Address,
+		URL:         e.URL,
 	}
-
-	return concerts, nil
 }
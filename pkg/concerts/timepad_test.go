@@ -0,0 +1,99 @@
+package concerts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shakareem/gigoseek/pkg/config"
+)
+
+// festivalID событие, которое timepad находит по любому из festivalArtists
+const festivalID = 100
+
+var festivalArtists = map[string]bool{"Сплин": true, "Би-2": true}
+
+// timepadServer отвечает на поиск по ключевому слову: "down" - ошибкой 503, артистам фестиваля -
+// общим событием, остальным - событием с id по длине имени
+var timepadServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	artist := r.URL.Query().Get("keywords")
+	if artist == "down" {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	event := Event{ID: len(artist), Name: artist, StartsAt: "2026-11-01T19:00:00+0300"}
+	if festivalArtists[artist] {
+		event = Event{ID: festivalID, Name: "Фестиваль", StartsAt: "2026-07-01T12:00:00+0300"}
+	}
+	json.NewEncoder(w).Encode(EventsResponse{Values: []Event{event}, Total: 1})
+}))
+
+// TestMain подставляет конфиг, в котором api timepad указывает на тестовый сервер
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "gigoseek-config")
+	if err != nil {
+		panic(err)
+	}
+
+	path := filepath.Join(dir, "config.json")
+	cfg := fmt.Sprintf(`{"timepad": {"api_url": %q, "concerts_category_id": "399"}}`, timepadServer.URL+"/v1/events.json")
+	if err := os.WriteFile(path, []byte(cfg), 0o600); err != nil {
+		panic(err)
+	}
+	os.Setenv(config.PathEnv, path)
+
+	code := m.Run()
+
+	timepadServer.Close()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestGetConcerts(t *testing.T) {
+	provider := &TimepadConcertProvider{}
+
+	t.Run("partial failure", func(t *testing.T) {
+		concerts, err := provider.GetConcerts(t.Context(), []string{"down", "Кино"}, "Москва")
+		if err != nil {
+			t.Fatalf("GetConcerts failed although one artist was found: %v", err)
+		}
+		if len(concerts) != 1 || concerts[0].Name != "Кино" {
+			t.Errorf("GetConcerts = %+v, want the concert of Кино", concerts)
+		}
+	})
+
+	t.Run("all failed", func(t *testing.T) {
+		_, err := provider.GetConcerts(t.Context(), []string{"down", "down"}, "Москва")
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("GetConcerts error = %v, want StatusError 503", err)
+		}
+	})
+
+	t.Run("same event for several artists", func(t *testing.T) {
+		concerts, err := provider.GetConcerts(t.Context(), []string{"Сплин", "Кино", "Би-2"}, "Москва")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []int{}
+		for _, c := range concerts {
+			ids = append(ids, c.ID)
+		}
+		if len(concerts) != 2 || concerts[0].ID != festivalID || concerts[1].Name != "Кино" {
+			t.Errorf("GetConcerts returned events %v, want the festival once and the concert of Кино", ids)
+		}
+	})
+
+	t.Run("no artists", func(t *testing.T) {
+		concerts, err := provider.GetConcerts(t.Context(), nil, "Москва")
+		if err != nil || len(concerts) != 0 {
+			t.Errorf("GetConcerts = %v, %v, want no concerts and no error", concerts, err)
+		}
+	})
+}
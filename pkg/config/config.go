@@ -5,37 +5,64 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 )
 
-const configFilePath = "configs/config.json"
-
-type Messages struct {
-	Start           string `json:"start"`
-	AuthPrompt      string `json:"auth_prompt"`
-	AuthSuccess     string `json:"auth_success"`
-	AuthFail        string `json:"auth_fail"`
-	Help            string `json:"help"`
-	FavoriteArtists string `json:"favorite_artists"`
-	EnterCity       string `json:"enter_city"`
-	CitySuccess     string `json:"city_success"`
-	NoFavorites     string `json:"no_favorites"`
-	NoConcerts      string `json:"no_concerts"`
-	WaitForConcerts string `json:"wait_for_concerts"`
-}
+const (
+	// PathEnv переменная окружения с путём к конфигу. Без неё конфиг читается из
+	// defaultPath относительно текущей директории, родительские директории не просматриваются
+	PathEnv     = "GIGOSEEK_CONFIG"
+	defaultPath = "configs/config.json"
+)
 
 type Database struct {
+	// Driver "postgres" (по умолчанию) или "sqlite"
+	Driver string `json:"driver"`
+	// DSN полная строка подключения к postgres, если задана, Host, Port, User, DBname и SSLMode не используются
+	DSN      string `json:"-"`
 	Host     string `json:"host"`
 	Port     int    `json:"port"`
 	User     string `json:"user"`
 	Password string `json:"password"`
 	DBname   string `json:"db_name"`
+	SSLMode  string `json:"sslmode"`
+	// Path файл базы для sqlite
+	Path string `json:"path"`
+
+	MaxOpenConns    int      `json:"max_open_conns"`
+	MaxIdleConns    int      `json:"max_idle_conns"`
+	ConnMaxLifetime Duration `json:"conn_max_lifetime"`
+	ConnMaxIdleTime Duration `json:"conn_max_idle_time"`
+	// StatementTimeout ограничение на выполнение одного запроса
+	StatementTimeout Duration `json:"statement_timeout"`
+	// ConnectTimeout сколько ждать доступности базы при запуске
+	ConnectTimeout Duration `json:"connect_timeout"`
+}
+
+// Duration time.Duration, который в конфиге записывается строкой: "30s", "5m"
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %w", err)
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
 }
 
 type TokensAndSecrets struct {
-	TelegramApiToken    string
-	SpotifyClientID     string
-	SpotifyClientSecret string
-	TimepadApiToken     string
+	TelegramApiToken      string
+	TelegramWebhookSecret string
+	SpotifyClientID       string
+	SpotifyClientSecret   string
+	TimepadApiToken       string
+	TokenEncryptionKeys   string
 }
 
 type TLS struct {
@@ -48,13 +75,27 @@ type Timepad struct {
 	ConcertsCategoryID string `json:"concerts_category_id"`
 }
 
+type Telegram struct {
+	UpdatesMode string `json:"updates_mode"`
+	WebhookURL  string `json:"webhook_url"`
+	WebhookPath string `json:"webhook_path"`
+	Workers     int    `json:"workers"`
+}
+
 type Config struct {
 	TokensAndSecrets
 	AuthServerURL string   `json:"auth_server_url"`
-	Messages      Messages `json:"messages"`
+	BotURL        string   `json:"bot_url"`
+	CalendarURL   string   `json:"calendar_url"`
+	AdminChatIDs  []int64  `json:"admin_chat_ids"`
 	Database      Database `json:"database"`
 	TLS           TLS      `json:"tls"`
 	Timepad       Timepad  `json:"timepad"`
+	Telegram      Telegram `json:"telegram"`
+	// DebugAddr адрес отладочного сервера с метриками /debug/vars, пустой - сервер не запускается
+	DebugAddr string `json:"debug_addr"`
+	// Templates заменяют шаблоны сообщений из каталогов: язык -> имя шаблона -> текст шаблона
+	Templates map[string]map[string]string `json:"templates"`
 }
 
 var cfg *Config
@@ -62,7 +103,7 @@ var cfg *Config
 func Get() *Config {
 	if cfg == nil {
 		var err error
-		cfg, err = loadConfig(configFilePath)
+		cfg, err = loadConfig(filePath())
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -71,6 +112,13 @@ func Get() *Config {
 	return cfg
 }
 
+func filePath() string {
+	if path := os.Getenv(PathEnv); path != "" {
+		return path
+	}
+	return defaultPath
+}
+
 func loadConfig(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -83,9 +131,12 @@ func loadConfig(path string) (*Config, error) {
 	}
 
 	cfg.TelegramApiToken = os.Getenv("TELEGRAM_API_TOKEN")
+	cfg.TelegramWebhookSecret = os.Getenv("TELEGRAM_WEBHOOK_SECRET")
 	cfg.SpotifyClientID = os.Getenv("SPOTIFY_CLIENT_ID")
 	cfg.SpotifyClientSecret = os.Getenv("SPOTIFY_CLIENT_SECRET")
 	cfg.Database.Password = os.Getenv("POSTGRES_PASSWORD")
+	cfg.Database.DSN = os.Getenv("DATABASE_URL")
+	cfg.TokenEncryptionKeys = os.Getenv("TOKEN_ENCRYPTION_KEYS")
 
 	return &cfg, nil
 }
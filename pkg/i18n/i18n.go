@@ -0,0 +1,96 @@
+// Package i18n каталоги сообщений бота на разных языках
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+)
+
+type Lang string
+
+const (
+	Russian Lang = "ru"
+	English Lang = "en"
+
+	// Default язык чатов, о которых ничего не известно. Из него берутся сообщения, которых нет в других каталогах
+	Default = Russian
+)
+
+// Languages поддерживаемые языки в порядке показа пользователю
+var Languages = []Lang{Russian, English}
+
+//go:embed locales/*.json
+var localesFS embed.FS
+
+var catalogs = map[Lang]*Messages{}
+
+func init() {
+	base, err := loadCatalog(Default, nil)
+	if err != nil {
+		panic(err)
+	}
+	catalogs[Default] = base
+
+	for _, lang := range Languages {
+		if lang == Default {
+			continue
+		}
+		if catalogs[lang], err = loadCatalog(lang, base); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// loadCatalog читает каталог языка поверх копии base, так что непереведённые сообщения берутся из base
+func loadCatalog(lang Lang, base *Messages) (*Messages, error) {
+	data, err := localesFS.ReadFile("locales/" + string(lang) + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s catalog: %w", lang, err)
+	}
+
+	m := &Messages{}
+	if base != nil {
+		*m = *base
+		m.Commands = maps.Clone(base.Commands)
+		m.CommandArgs = maps.Clone(base.CommandArgs)
+		m.Templates = maps.Clone(base.Templates)
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("failed to parse %s catalog: %w", lang, err)
+	}
+	m.Lang = lang
+	return m, nil
+}
+
+// Parse разбирает код языка вида "en" или "en-US" и сообщает, поддерживается ли он
+func Parse(code string) (Lang, bool) {
+	primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(code)), "-")
+	for _, lang := range Languages {
+		if string(lang) == primary {
+			return lang, true
+		}
+	}
+	return "", false
+}
+
+// Match выбирает язык по language_code из Telegram: без кода - Default, с неподдерживаемым - английский
+func Match(code string) Lang {
+	if lang, ok := Parse(code); ok {
+		return lang
+	}
+	if strings.TrimSpace(code) == "" {
+		return Default
+	}
+	return English
+}
+
+// Get возвращает каталог языка, для неизвестного языка - каталог Default
+func Get(lang Lang) *Messages {
+	if m, ok := catalogs[lang]; ok {
+		return m
+	}
+	return catalogs[Default]
+}
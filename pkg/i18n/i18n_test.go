@@ -0,0 +1,82 @@
+package i18n
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestPlural(t *testing.T) {
+	tests := []struct {
+		lang Lang
+		n    int
+		want string
+	}{
+		{Russian, 1, "Найдено 1 событие:"},
+		{Russian, 3, "Найдено 3 события:"},
+		{Russian, 5, "Найдено 5 событий:"},
+		{Russian, 11, "Найдено 11 событий:"},
+		{Russian, 12, "Найдено 12 событий:"},
+		{Russian, 21, "Найдено 21 событие:"},
+		{Russian, 24, "Найдено 24 события:"},
+		{Russian, 111, "Найдено 111 событий:"},
+		{English, 0, "Found 0 events:"},
+		{English, 1, "Found 1 event:"},
+		{English, 2, "Found 2 events:"},
+	}
+
+	for _, tt := range tests {
+		m := Get(tt.lang)
+		if got := m.Plural(m.ConcertsFound, tt.n); got != tt.want {
+			t.Errorf("%s Plural(%d) = %q, want %q", tt.lang, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := map[string]Lang{
+		"":      Default,
+		"ru":    Russian,
+		"en-US": English,
+		"EN":    English,
+		"de":    English,
+	}
+	for code, want := range tests {
+		if got := Match(code); got != want {
+			t.Errorf("Match(%q) = %q, want %q", code, got, want)
+		}
+	}
+}
+
+// TestCatalogsComplete каждый каталог переводит все сообщения, а не полагается на Default
+func TestCatalogsComplete(t *testing.T) {
+	keys := func(lang Lang) map[string]json.RawMessage {
+		data, err := localesFS.ReadFile("locales/" + string(lang) + ".json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var catalog map[string]json.RawMessage
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			t.Fatal(err)
+		}
+		return catalog
+	}
+
+	base := keys(Default)
+	for _, lang := range Languages {
+		catalog := keys(lang)
+		for key := range base {
+			if _, ok := catalog[key]; !ok {
+				t.Errorf("%s catalog has no %q", lang, key)
+			}
+		}
+
+		m := Get(lang)
+		for _, name := range slices.Sorted(maps.Keys(Get(Default).Commands)) {
+			if m.Commands[name] == "" {
+				t.Errorf("%s catalog has no description of /%s", lang, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package i18n
+
+// Messages все тексты, которые видит пользователь
+type Messages struct {
+	Lang Lang `json:"-"`
+
+	// LanguageName название языка на нём самом, показывается в /language
+	LanguageName string `json:"language_name"`
+
+	Start                string `json:"start"`
+	AuthPrompt           string `json:"auth_prompt"`
+	AuthSuccess          string `json:"auth_success"`
+	AuthFail             string `json:"auth_fail"`
+	AuthDenied           string `json:"auth_denied"`
+	ReauthRequired       string `json:"reauth_required"`
+	AuthRetry            string `json:"auth_retry"`
+	AuthPageSuccess      string `json:"auth_page_success"`
+	AuthPageFail         string `json:"auth_page_fail"`
+	AuthPageDenied       string `json:"auth_page_denied"`
+	AuthPageInvalidState string `json:"auth_page_invalid_state"`
+	BackToBot            string `json:"back_to_bot"`
+	LogoutSuccess        string `json:"logout_success"`
+	DeleteMeConfirm      string `json:"delete_me_confirm"`
+	DeleteMeButton       string `json:"delete_me_button"`
+	DeleteMeSuccess      string `json:"delete_me_success"`
+	Export               string `json:"export"`
+	CommandUsage         string `json:"command_usage"`
+	Canceled             string `json:"canceled"`
+	NothingToCancel      string `json:"nothing_to_cancel"`
+	InputExpired         string `json:"input_expired"`
+	ErrorRetryable       string `json:"error_retryable"`
+	ErrorInternal        string `json:"error_internal"`
+	LanguagePrompt       string `json:"language_prompt"`
+	LanguageSet          string `json:"language_set"`
+	LanguageUnknown      string `json:"language_unknown"`
+	EnterCity            string `json:"enter_city"`
+	CitySuccess          string `json:"city_success"`
+	CityNotFound         string `json:"city_not_found"`
+	NoFavorites          string `json:"no_favorites"`
+	NoConcerts           string `json:"no_concerts"`
+	WaitForConcerts      string `json:"wait_for_concerts"`
+	ConcertsFound        Plural `json:"concerts_found"`
+	ConcertsTruncated    string `json:"concerts_truncated"`
+	AddToCalendar        string `json:"add_to_calendar"`
+	CalendarFeed         string `json:"calendar_feed"`
+	RemindMe             string `json:"remind_me"`
+	ReminderSet          string `json:"reminder_set"`
+	ReminderTooLate      string `json:"reminder_too_late"`
+	NoReminders          string `json:"no_reminders"`
+	CancelReminder       string `json:"cancel_reminder"`
+	ReminderCanceled     string `json:"reminder_canceled"`
+
+	// Commands описания команд для /help и меню Telegram по имени команды
+	Commands map[string]string `json:"commands"`
+	// CommandArgs названия аргументов команд для подсказок
+	CommandArgs map[string]string `json:"command_args"`
+	// Templates шаблоны text/template для сообщений с HTML-разметкой Telegram, значения в них экранируются
+	Templates map[string]string `json:"templates"`
+
+	// сообщения для админских команд справочника городов
+	CityAdded         string `json:"city_added"`
+	CityUpdated       string `json:"city_updated"`
+	CityAdminUsage    string `json:"city_admin_usage"`
+	CityCard          string `json:"city_card"`
+	CityExists        string `json:"city_exists"`
+	CityAliasExists   string `json:"city_alias_exists"`
+	CityNameRequired  string `json:"city_name_required"`
+	CityFieldFormat   string `json:"city_field_format"`
+	CityNameEmpty     string `json:"city_name_empty"`
+	CityTimepadEmpty  string `json:"city_timepad_empty"`
+	CityBadLatitude   string `json:"city_bad_latitude"`
+	CityBadLongitude  string `json:"city_bad_longitude"`
+	CityBadTimezone   string `json:"city_bad_timezone"`
+	CityBadPopulation string `json:"city_bad_population"`
+	CityUnknownField  string `json:"city_unknown_field"`
+}
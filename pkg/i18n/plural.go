@@ -0,0 +1,52 @@
+package i18n
+
+import "fmt"
+
+// Plural формы сообщения для разных чисел. Какие формы нужны, зависит от языка:
+// в русском one, few и many, в английском one и other
+type Plural struct {
+	One   string `json:"one"`
+	Few   string `json:"few"`
+	Many  string `json:"many"`
+	Other string `json:"other"`
+}
+
+// Plural подставляет n в форму сообщения, подходящую для n в языке каталога
+func (m *Messages) Plural(p Plural, n int) string {
+	return fmt.Sprintf(p.form(pluralCategory(m.Lang, n)), n)
+}
+
+func (p Plural) form(category string) string {
+	forms := map[string]string{"one": p.One, "few": p.Few, "many": p.Many, "other": p.Other}
+	if form := forms[category]; form != "" {
+		return form
+	}
+	if p.Other != "" {
+		return p.Other
+	}
+	return p.Many
+}
+
+// pluralCategory правила CLDR для целых чисел
+func pluralCategory(lang Lang, n int) string {
+	if n < 0 {
+		n = -n
+	}
+
+	switch lang {
+	case Russian:
+		switch mod10, mod100 := n%10, n%100; {
+		case mod10 == 1 && mod100 != 11:
+			return "one"
+		case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+			return "few"
+		default:
+			return "many"
+		}
+	default:
+		if n == 1 {
+			return "one"
+		}
+		return "other"
+	}
+}
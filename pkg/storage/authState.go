@@ -0,0 +1,15 @@
+package storage
+
+import "time"
+
+type AuthState struct {
+	State     string
+	ChatID    int64
+	CreatedAt time.Time
+	// CodeVerifier секрет PKCE, который передаётся при обмене кода на токен
+	CodeVerifier string
+}
+
+func (s AuthState) Expired(ttl time.Duration, now time.Time) bool {
+	return now.Sub(s.CreatedAt) > ttl
+}
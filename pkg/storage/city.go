@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrCityNotFound город не найден ни по названию, ни по альтернативному написанию
+var ErrCityNotFound = errors.New("city not found")
+
+// City город из справочника
+type City struct {
+	ID   int    `json:"-"`
+	Name string `json:"name"`
+	// TimepadName название, под которым город ищется в timepad
+	TimepadName string `json:"timepad_name"`
+	// Aliases альтернативные написания в нижнем регистре: "питер", "spb"
+	Aliases    []string `json:"aliases,omitempty"`
+	Latitude   float64  `json:"latitude"`
+	Longitude  float64  `json:"longitude"`
+	Timezone   string   `json:"timezone"`
+	Population int      `json:"population"`
+}
+
+// normalizeCityName приводит название к виду, в котором хранятся альтернативные написания
+func normalizeCityName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+func normalizeAliases(aliases []string) []string {
+	normalized := make([]string, 0, len(aliases))
+	seen := map[string]bool{}
+	for _, alias := range aliases {
+		alias = normalizeCityName(alias)
+		if alias == "" || seen[alias] {
+			continue
+		}
+		seen[alias] = true
+		normalized = append(normalized, alias)
+	}
+	return normalized
+}
+
+// catalogueFile справочник городов, который миграции загружают в базу, а InMemoryStorage - в память
+const catalogueFile = "migrations/data/city_catalogue.sql"
+
+var (
+	catalogueCityRow  = regexp.MustCompile(`(?m)^\s*\('([^']*)', '([^']*)', (-?[\d.]+), (-?[\d.]+), '([^']*)', (\d+)\),?$`)
+	catalogueAliasRow = regexp.MustCompile(`(?m)^\s*\('([^']*)', '([^']*)'\),?$`)
+)
+
+// catalogue города из catalogueFile. Файл разбирается построчно: строки городов и синонимов
+// записаны в нём по одной на строку, как их пишут миграции
+var catalogue = sync.OnceValues(func() ([]City, error) {
+	data, err := migrationsFS.ReadFile(catalogueFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// синонимы перечислены в WITH после вставки городов
+	cityRows, aliasRows, ok := strings.Cut(string(data), "WITH a ")
+	if !ok {
+		return nil, fmt.Errorf("%s: no aliases", catalogueFile)
+	}
+
+	var cities []City
+	byName := map[string]int{}
+	for _, row := range catalogueCityRow.FindAllStringSubmatch(cityRows, -1) {
+		city := City{Name: row[1], TimepadName: row[2], Timezone: row[5]}
+		var errLat, errLon, errPop error
+		city.Latitude, errLat = strconv.ParseFloat(row[3], 64)
+		city.Longitude, errLon = strconv.ParseFloat(row[4], 64)
+		city.Population, errPop = strconv.Atoi(row[6])
+		if err := errors.Join(errLat, errLon, errPop); err != nil {
+			return nil, fmt.Errorf("%s: city %q: %w", catalogueFile, city.Name, err)
+		}
+		byName[city.Name] = len(cities)
+		cities = append(cities, city)
+	}
+
+	for _, row := range catalogueAliasRow.FindAllStringSubmatch(aliasRows, -1) {
+		i, ok := byName[row[2]]
+		if !ok {
+			return nil, fmt.Errorf("%s: alias %q of unknown city %q", catalogueFile, row[1], row[2])
+		}
+		cities[i].Aliases = append(cities[i].Aliases, row[1])
+	}
+
+	if len(cities) == 0 {
+		return nil, fmt.Errorf("%s: no cities", catalogueFile)
+	}
+	return cities, nil
+})
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCatalogue(t *testing.T) {
+	cities, err := catalogue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := migrationsFS.ReadFile(catalogueFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// каждая строка данных должна разобраться: иначе InMemoryStorage молча потеряет город
+	if rows := strings.Count(string(data), "\n  ('"); rows != len(cities)+countAliases(cities) {
+		t.Errorf("parsed %d cities and %d aliases from %d rows", len(cities), countAliases(cities), rows)
+	}
+
+	for _, city := range cities {
+		if _, err := time.LoadLocation(city.Timezone); err != nil {
+			t.Errorf("city %q has unknown timezone %q", city.Name, city.Timezone)
+		}
+		if city.Latitude < -90 || city.Latitude > 90 || city.Longitude < -180 || city.Longitude > 180 {
+			t.Errorf("city %q has invalid coordinates %v, %v", city.Name, city.Latitude, city.Longitude)
+		}
+	}
+}
+
+func countAliases(cities []City) int {
+	n := 0
+	for _, city := range cities {
+		n += len(city.Aliases)
+	}
+	return n
+}
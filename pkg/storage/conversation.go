@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// ChatState название состояния диалога. Сами состояния и переходы между ними описывает пакет telegram
+type ChatState string
+
+// DefaultChatState состояние чата, который ничего не ждёт от пользователя
+const DefaultChatState ChatState = "idle"
+
+// Conversation состояние диалога вместе с данными, которые нужны этому состоянию
+type Conversation struct {
+	State ChatState `json:"state"`
+	// Payload данные состояния в json, у каждого состояния свои
+	Payload json.RawMessage `json:"payload,omitempty"`
+	// UpdatedAt когда чат перешёл в это состояние, по нему истекают состояния с таймаутом
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func nullPayload(payload json.RawMessage) sql.NullString {
+	return sql.NullString{String: string(payload), Valid: len(payload) > 0}
+}
+
+func scanConversation(row *sql.Row) (Conversation, error) {
+	var conv Conversation
+	var payload sql.NullString
+	var updatedAt sql.NullTime
+
+	err := row.Scan(&conv.State, &payload, &updatedAt)
+	if err == sql.ErrNoRows {
+		return Conversation{}, ErrChatNotFound
+	}
+	if err != nil {
+		return Conversation{}, err
+	}
+
+	if payload.Valid {
+		conv.Payload = json.RawMessage(payload.String)
+	}
+	conv.UpdatedAt = updatedAt.Time
+	return conv, nil
+}
@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shakareem/gigoseek/pkg/config"
+)
+
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+const (
+	defaultConnectTimeout = 30 * time.Second
+	connectRetryMin       = 500 * time.Millisecond
+	connectRetryMax       = 8 * time.Second
+)
+
+// ConfiguredDriver возвращает драйвер из database.driver, по умолчанию postgres
+func ConfiguredDriver() (string, error) {
+	switch driver := config.Get().Database.Driver; driver {
+	case "", DriverPostgres:
+		return DriverPostgres, nil
+	case DriverSQLite:
+		return DriverSQLite, nil
+	default:
+		return "", fmt.Errorf("unknown database driver %q", driver)
+	}
+}
+
+// Options настройки пула соединений и таймаутов. Нулевые значения оставляют умолчания database/sql
+type Options struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// StatementTimeout в postgres передаётся как statement_timeout, в sqlite как busy_timeout
+	StatementTimeout time.Duration
+	// ConnectTimeout сколько повторять проверку соединения при открытии, по умолчанию 30 секунд
+	ConnectTimeout time.Duration
+	// SkipMigrations открыть базу, не применяя миграции: нужно, чтобы откатить их
+	SkipMigrations bool
+}
+
+func optionsFromConfig(cfg config.Database, migrate bool) Options {
+	return Options{
+		MaxOpenConns:     cfg.MaxOpenConns,
+		MaxIdleConns:     cfg.MaxIdleConns,
+		ConnMaxLifetime:  time.Duration(cfg.ConnMaxLifetime),
+		ConnMaxIdleTime:  time.Duration(cfg.ConnMaxIdleTime),
+		StatementTimeout: time.Duration(cfg.StatementTimeout),
+		ConnectTimeout:   time.Duration(cfg.ConnectTimeout),
+		SkipMigrations:   !migrate,
+	}
+}
+
+func (o Options) applyPool(db *sql.DB) {
+	db.SetMaxOpenConns(o.MaxOpenConns)
+	if o.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(o.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(o.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(o.ConnMaxIdleTime)
+}
+
+// waitForDatabase проверяет соединение, повторяя попытки с растущей паузой:
+// при запуске через docker compose база может подниматься дольше бота
+func waitForDatabase(ctx context.Context, db *sql.DB, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = defaultConnectTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	backoff := connectRetryMin
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("database is not reachable after %d attempts: %w", attempt, err)
+		}
+
+		log.Printf("Database is not reachable (attempt %d), retrying in %s: %v", attempt, backoff, err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("database is not reachable after %d attempts: %w", attempt, err)
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, connectRetryMax)
+	}
+}
+
+// postgresDSN собирает строку подключения из конфига. Полная строка из DATABASE_URL используется как есть
+func postgresDSN(cfg config.Database) string {
+	if cfg.DSN != "" {
+		return cfg.DSN
+	}
+
+	sslMode := cfg.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+
+	var port string
+	if cfg.Port != 0 {
+		port = strconv.Itoa(cfg.Port)
+	}
+
+	params := []struct{ key, value string }{
+		{"host", cfg.Host},
+		{"port", port},
+		{"user", cfg.User},
+		{"password", cfg.Password},
+		{"dbname", cfg.DBname},
+		{"sslmode", sslMode},
+	}
+
+	var dsn []string
+	for _, p := range params {
+		if p.value != "" {
+			dsn = append(dsn, p.key+"="+quoteDSNValue(p.value))
+		}
+	}
+	return strings.Join(dsn, " ")
+}
+
+// quoteDSNValue экранирует значение в формате key=value, чтобы пароль с пробелами или кавычками не ломал строку
+func quoteDSNValue(value string) string {
+	if !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	value = strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+	return "'" + value + "'"
+}
+
+// withDSNParam добавляет параметр к строке подключения в формате url или key=value, если он не задан явно.
+// lib/pq передаёт неизвестные ему параметры серверу как настройки сессии
+func withDSNParam(dsn, key, value string) (string, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "", fmt.Errorf("invalid database url: %w", err)
+		}
+		query := u.Query()
+		if !query.Has(key) {
+			query.Set(key, value)
+		}
+		u.RawQuery = query.Encode()
+		return u.String(), nil
+	}
+
+	if strings.Contains(dsn, key+"=") {
+		return dsn, nil
+	}
+	return strings.TrimSpace(dsn + " " + key + "=" + quoteDSNValue(value)), nil
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/shakareem/gigoseek/pkg/config"
+)
+
+func TestPostgresDSN(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Database
+		want string
+	}{
+		{
+			name: "defaults sslmode",
+			cfg:  config.Database{Host: "db", Port: 5432, User: "postgres", Password: "secret", DBname: "postgres"},
+			want: "host=db port=5432 user=postgres password=secret dbname=postgres sslmode=disable",
+		},
+		{
+			name: "quotes password",
+			cfg:  config.Database{Host: "db", User: "postgres", Password: `it's a \secret`, SSLMode: "verify-full"},
+			want: `host=db user=postgres password='it\'s a \\secret' sslmode=verify-full`,
+		},
+		{
+			name: "full dsn wins",
+			cfg:  config.Database{DSN: "postgres://u:p@host/db", Host: "db"},
+			want: "postgres://u:p@host/db",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := postgresDSN(tt.cfg); got != tt.want {
+				t.Errorf("postgresDSN() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithDSNParam(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{"host=db sslmode=disable", "host=db sslmode=disable statement_timeout=5000"},
+		{"host=db statement_timeout=100", "host=db statement_timeout=100"},
+		{"postgres://u:p@host/db?sslmode=require", "postgres://u:p@host/db?sslmode=require&statement_timeout=5000"},
+		{"postgres://u:p@host/db?statement_timeout=100", "postgres://u:p@host/db?statement_timeout=100"},
+	}
+
+	for _, tt := range tests {
+		got, err := withDSNParam(tt.dsn, "statement_timeout", "5000")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("withDSNParam(%q) = %q, want %q", tt.dsn, got, tt.want)
+		}
+	}
+}
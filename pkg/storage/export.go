@@ -0,0 +1,22 @@
+package storage
+
+import "time"
+
+// ChatExport все данные, которые хранятся о чате, без секретов
+type ChatExport struct {
+	ChatID        int64          `json:"chat_id"`
+	ChatState     ChatState      `json:"chat_state"`
+	Language      string         `json:"language,omitempty"`
+	City          string         `json:"city,omitempty"`
+	Spotify       *LinkedAccount `json:"spotify,omitempty"`
+	CalendarFeed  bool           `json:"calendar_feed"`
+	PendingAuth   int            `json:"pending_auth_requests"`
+	Reminders     []Reminder     `json:"reminders"`
+	Notifications []Reminder     `json:"notifications"`
+}
+
+// LinkedAccount привязанный аккаунт без токенов
+type LinkedAccount struct {
+	TokenType   string    `json:"token_type"`
+	TokenExpiry time.Time `json:"token_expiry"`
+}
@@ -1,97 +1,581 @@
 package storage
 
 import (
+	"context"
 	"errors"
-	"log"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
 
 	"golang.org/x/oauth2"
 )
 
+// InMemoryStorage хранит всё в памяти процесса, данные теряются при перезапуске.
+// Все методы защищены мьютексом, так что хранилище можно использовать из нескольких обработчиков сразу
 type InMemoryStorage struct {
-	states     map[string]int64
+	mu sync.RWMutex
+
+	states     map[string]AuthState
 	tokens     map[int64]oauth2.Token
-	cities     map[int64]string
-	chatStates map[int64]ChatState
+	catalog    map[int]City
+	cities     map[int64]int
+	chatStates map[int64]Conversation
+	languages  map[int64]string
+	activity   map[int64]time.Time
+	calendars  map[int64]string
+	reminders  map[int64]Reminder
+	reminderID int64
+	cityID     int
 }
 
+// NewInMemoryStorage создаёт пустое хранилище со справочником городов, тем же, что загружают миграции
 func NewInMemoryStorage() *InMemoryStorage {
-	return &InMemoryStorage{
-		states:     make(map[string]int64),
+	s := &InMemoryStorage{
+		states:     make(map[string]AuthState),
 		tokens:     make(map[int64]oauth2.Token),
-		cities:     make(map[int64]string),
-		chatStates: make(map[int64]ChatState),
+		catalog:    make(map[int]City),
+		cities:     make(map[int64]int),
+		chatStates: make(map[int64]Conversation),
+		languages:  make(map[int64]string),
+		activity:   make(map[int64]time.Time),
+		calendars:  make(map[int64]string),
+		reminders:  make(map[int64]Reminder),
+	}
+
+	cities, err := catalogue()
+	if err != nil {
+		// справочник вшит в бинарник, ошибка в нём видна в тестах
+		panic(err)
+	}
+	for _, city := range cities {
+		city.Aliases = normalizeAliases(city.Aliases)
+		s.cityID++
+		city.ID = s.cityID
+		s.catalog[city.ID] = city
 	}
+
+	return s
 }
 
-func (s *InMemoryStorage) SaveState(state string, chatID int64) error {
-	s.states[state] = chatID
+func (s *InMemoryStorage) Close() error {
+	return nil
+}
+
+// WithTx выполняет fn над копией данных и сохраняет копию, только если fn завершилась без ошибки.
+// Пока идёт транзакция, остальные вызовы ждут, как при сериализуемой изоляции
+func (s *InMemoryStorage) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := s.clone()
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	s.states, s.tokens, s.catalog, s.cities = tx.states, tx.tokens, tx.catalog, tx.cities
+	s.chatStates, s.languages, s.calendars, s.reminders = tx.chatStates, tx.languages, tx.calendars, tx.reminders
+	s.activity = tx.activity
+	s.reminderID, s.cityID = tx.reminderID, tx.cityID
+	return nil
+}
+
+// clone копирует данные без блокировки, вызывающий должен держать мьютекс.
+// Значения в картах не изменяются на месте, поэтому достаточно копировать сами карты
+func (s *InMemoryStorage) clone() *InMemoryStorage {
+	return &InMemoryStorage{
+		states:     maps.Clone(s.states),
+		tokens:     maps.Clone(s.tokens),
+		catalog:    maps.Clone(s.catalog),
+		cities:     maps.Clone(s.cities),
+		chatStates: maps.Clone(s.chatStates),
+		languages:  maps.Clone(s.languages),
+		activity:   maps.Clone(s.activity),
+		calendars:  maps.Clone(s.calendars),
+		reminders:  maps.Clone(s.reminders),
+		reminderID: s.reminderID,
+		cityID:     s.cityID,
+	}
+}
+
+func (s *InMemoryStorage) SaveAuthState(ctx context.Context, authState AuthState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for state, saved := range s.states {
+		if saved.ChatID == authState.ChatID {
+			delete(s.states, state)
+		}
+	}
+	authState.CreatedAt = time.Now()
+	s.states[authState.State] = authState
 	return nil
 }
 
-func (s *InMemoryStorage) GetChatIDbyState(state string) (int64, error) {
-	chatID, ok := s.states[state]
+func (s *InMemoryStorage) ConsumeAuthState(ctx context.Context, state string) (AuthState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	authState, ok := s.states[state]
 	if !ok {
-		return 0, errors.New("state not found in storage")
+		return AuthState{}, errors.New("state not found in storage")
+	}
+	delete(s.states, state)
+	return authState, nil
+}
+
+func (s *InMemoryStorage) DeleteExpiredAuthStates(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for state, authState := range s.states {
+		if authState.CreatedAt.Before(before) {
+			delete(s.states, state)
+			deleted++
+		}
 	}
-	return chatID, nil
+	return deleted, nil
 }
 
-func (s *InMemoryStorage) DeleteState(state string) error {
+func (s *InMemoryStorage) DeleteAuthState(ctx context.Context, state string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	delete(s.states, state)
 	return nil
 }
 
-func (s *InMemoryStorage) SaveToken(chatID int64, token oauth2.Token) error {
+func (s *InMemoryStorage) DeleteAuthStates(ctx context.Context, chatID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for state, authState := range s.states {
+		if authState.ChatID == chatID {
+			delete(s.states, state)
+		}
+	}
+	return nil
+}
+
+func (s *InMemoryStorage) SaveToken(ctx context.Context, chatID int64, token oauth2.Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// как внешний ключ в postgres: токен можно сохранить только для известного чата
+	if _, ok := s.chatStates[chatID]; !ok {
+		return ErrChatNotFound
+	}
 	s.tokens[chatID] = token
 	return nil
 }
 
-func (s *InMemoryStorage) GetToken(chatID int64) (oauth2.Token, error) {
+func (s *InMemoryStorage) GetToken(ctx context.Context, chatID int64) (oauth2.Token, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	token, ok := s.tokens[chatID]
 	if !ok {
 		return oauth2.Token{}, errors.New("client not found in storage")
 	}
-	log.Printf("Token for chat %d retrieved successfully:\n%v", chatID, token)
 	return token, nil
 }
 
-func (s *InMemoryStorage) DeleteToken(chatID int64) error {
+func (s *InMemoryStorage) GetExpiringTokens(ctx context.Context, before, activeSince time.Time) ([]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var chatIDs []int64
+	for chatID, token := range s.tokens {
+		activeAt, ok := s.activity[chatID]
+		if token.Expiry.Before(before) && ok && !activeAt.Before(activeSince) {
+			chatIDs = append(chatIDs, chatID)
+		}
+	}
+	return chatIDs, nil
+}
+
+func (s *InMemoryStorage) DeleteToken(ctx context.Context, chatID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	delete(s.tokens, chatID)
 	return nil
 }
 
-func (s *InMemoryStorage) SaveCity(chatID int64, city string) error {
-	s.cities[chatID] = city
+func (s *InMemoryStorage) SaveCity(ctx context.Context, chatID int64, city string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found, err := s.findCity(city)
+	if err != nil {
+		return fmt.Errorf("city %q: %w", city, err)
+	}
+	if _, ok := s.chatStates[chatID]; !ok {
+		return ErrChatNotFound
+	}
+	s.cities[chatID] = found.ID
 	return nil
 }
 
-func (s *InMemoryStorage) GetCity(chatID int64) (string, error) {
-	city, ok := s.cities[chatID]
+func (s *InMemoryStorage) GetCity(ctx context.Context, chatID int64) (City, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cityID, ok := s.cities[chatID]
 	if !ok {
-		return "", errors.New("city not found in storage")
+		return City{}, errors.New("city not found in storage")
 	}
+	return s.catalog[cityID], nil
+}
+
+func (s *InMemoryStorage) FindCity(ctx context.Context, name string) (City, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.findCity(name)
+}
+
+func (s *InMemoryStorage) findCity(name string) (City, error) {
+	name = normalizeCityName(name)
+	for _, city := range s.catalog {
+		if normalizeCityName(city.Name) == name || slices.Contains(city.Aliases, name) {
+			return city, nil
+		}
+	}
+	return City{}, ErrCityNotFound
+}
+
+func (s *InMemoryStorage) AddCity(ctx context.Context, city City) (City, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	city.Aliases = normalizeAliases(city.Aliases)
+	if err := s.checkCityUnique(city); err != nil {
+		return City{}, err
+	}
+
+	s.cityID++
+	city.ID = s.cityID
+	s.catalog[city.ID] = city
 	return city, nil
 }
 
-func (s *InMemoryStorage) DeleteCity(chatID int64) error {
+func (s *InMemoryStorage) UpdateCity(ctx context.Context, city City) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.catalog[city.ID]; !ok {
+		return ErrCityNotFound
+	}
+	city.Aliases = normalizeAliases(city.Aliases)
+	if err := s.checkCityUnique(city); err != nil {
+		return err
+	}
+
+	s.catalog[city.ID] = city
+	return nil
+}
+
+// checkCityUnique повторяет ограничения уникальности таблиц city и city_alias
+func (s *InMemoryStorage) checkCityUnique(city City) error {
+	for id, other := range s.catalog {
+		if id == city.ID {
+			continue
+		}
+		if other.Name == city.Name {
+			return fmt.Errorf("city %q already exists", city.Name)
+		}
+		for _, alias := range city.Aliases {
+			if slices.Contains(other.Aliases, alias) {
+				return fmt.Errorf("alias %q: already used by %q", alias, other.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *InMemoryStorage) DeleteCity(ctx context.Context, chatID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	delete(s.cities, chatID)
 	return nil
 }
 
-func (s *InMemoryStorage) SaveChatState(chatID int64, state ChatState) error {
-	s.chatStates[chatID] = state
+func (s *InMemoryStorage) SaveChatState(ctx context.Context, chatID int64, state ChatState) error {
+	return s.SaveConversation(ctx, chatID, Conversation{State: state})
+}
+
+func (s *InMemoryStorage) SaveConversation(ctx context.Context, chatID int64, conv Conversation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if conv.UpdatedAt.IsZero() {
+		conv.UpdatedAt = time.Now()
+	}
+	conv.Payload = slices.Clone(conv.Payload)
+	s.chatStates[chatID] = conv
 	return nil
 }
 
-func (s *InMemoryStorage) GetChatState(chatID int64) (ChatState, error) {
-	state, ok := s.chatStates[chatID]
+func (s *InMemoryStorage) GetConversation(ctx context.Context, chatID int64) (Conversation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	conv, ok := s.chatStates[chatID]
 	if !ok {
-		return 0, errors.New("chat state not found in storage")
+		return Conversation{}, ErrChatNotFound
+	}
+	conv.Payload = slices.Clone(conv.Payload)
+	return conv, nil
+}
+
+func (s *InMemoryStorage) DeleteChatState(ctx context.Context, chatID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// как и в postgres, чат остаётся, а состояние сбрасывается
+	if _, ok := s.chatStates[chatID]; ok {
+		s.chatStates[chatID] = Conversation{State: DefaultChatState, UpdatedAt: time.Now()}
+	}
+	return nil
+}
+
+func (s *InMemoryStorage) SaveChatActivity(ctx context.Context, chatID int64, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.chatStates[chatID]; ok {
+		s.activity[chatID] = at
+	}
+	return nil
+}
+
+func (s *InMemoryStorage) SaveLanguage(ctx context.Context, chatID int64, lang string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.chatStates[chatID]; !ok {
+		return ErrChatNotFound
 	}
-	return state, nil
+	s.languages[chatID] = lang
+	return nil
 }
 
-func (s *InMemoryStorage) DeleteChatState(chatID int64) error {
+func (s *InMemoryStorage) GetLanguage(ctx context.Context, chatID int64) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.chatStates[chatID]; !ok {
+		return "", ErrChatNotFound
+	}
+	return s.languages[chatID], nil
+}
+
+func (s *InMemoryStorage) DeleteChat(ctx context.Context, chatID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for state, authState := range s.states {
+		if authState.ChatID == chatID {
+			delete(s.states, state)
+		}
+	}
+	for id, reminder := range s.reminders {
+		if reminder.ChatID == chatID {
+			delete(s.reminders, id)
+		}
+	}
+	delete(s.tokens, chatID)
+	delete(s.cities, chatID)
 	delete(s.chatStates, chatID)
+	delete(s.languages, chatID)
+	delete(s.activity, chatID)
+	delete(s.calendars, chatID)
+	return nil
+}
+
+func (s *InMemoryStorage) SaveCalendarToken(ctx context.Context, chatID int64, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calendars[chatID] = token
+	return nil
+}
+
+func (s *InMemoryStorage) GetCalendarToken(ctx context.Context, chatID int64) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	token, ok := s.calendars[chatID]
+	if !ok {
+		return "", errors.New("calendar token not found in storage")
+	}
+	return token, nil
+}
+
+func (s *InMemoryStorage) GetChatIDbyCalendarToken(ctx context.Context, token string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for chatID, t := range s.calendars {
+		if t == token {
+			return chatID, nil
+		}
+	}
+	return 0, errors.New("calendar token not found in storage")
+}
+
+func (s *InMemoryStorage) DeleteCalendarToken(ctx context.Context, chatID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.calendars, chatID)
 	return nil
 }
+
+func (s *InMemoryStorage) SaveReminder(ctx context.Context, reminder Reminder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range s.reminders {
+		if r.ChatID == reminder.ChatID && r.ConcertID == reminder.ConcertID && r.RemindAt.Equal(reminder.RemindAt) {
+			return nil
+		}
+	}
+
+	s.reminderID++
+	reminder.ID = s.reminderID
+	s.reminders[reminder.ID] = reminder
+	return nil
+}
+
+func (s *InMemoryStorage) GetReminders(ctx context.Context, chatID int64) ([]Reminder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.filterReminders(func(r Reminder) bool { return r.ChatID == chatID && r.SentAt == nil }), nil
+}
+
+func (s *InMemoryStorage) GetDueReminders(ctx context.Context, now time.Time) ([]Reminder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reminders := s.filterReminders(func(r Reminder) bool { return !r.DueAt().After(now) && r.SentAt == nil })
+	sort.SliceStable(reminders, func(i, j int) bool {
+		return reminders[i].DueAt().Before(reminders[j].DueAt())
+	})
+	return reminders, nil
+}
+
+func (s *InMemoryStorage) MarkReminderSent(ctx context.Context, id int64, sentAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reminder, ok := s.reminders[id]
+	if !ok {
+		return errors.New("reminder not found in storage")
+	}
+	reminder.SentAt = &sentAt
+	s.reminders[id] = reminder
+	return nil
+}
+
+func (s *InMemoryStorage) RetryReminder(ctx context.Context, id int64, retryAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reminder, ok := s.reminders[id]
+	if !ok {
+		return errors.New("reminder not found in storage")
+	}
+	reminder.RetryAt = &retryAt
+	reminder.Attempts++
+	s.reminders[id] = reminder
+	return nil
+}
+
+func (s *InMemoryStorage) filterReminders(match func(Reminder) bool) []Reminder {
+	reminders := []Reminder{}
+	for _, r := range s.reminders {
+		if match(r) {
+			reminders = append(reminders, r)
+		}
+	}
+	sort.Slice(reminders, func(i, j int) bool {
+		return reminders[i].RemindAt.Before(reminders[j].RemindAt)
+	})
+	return reminders
+}
+
+func (s *InMemoryStorage) DeleteReminder(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.reminders, id)
+	return nil
+}
+
+func (s *InMemoryStorage) DeleteSentReminders(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for id, r := range s.reminders {
+		if r.SentAt != nil && r.SentAt.Before(before) {
+			delete(s.reminders, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *InMemoryStorage) DeleteConcertReminders(ctx context.Context, chatID int64, concertID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, r := range s.reminders {
+		if r.ChatID == chatID && r.ConcertID == concertID && r.SentAt == nil {
+			delete(s.reminders, id)
+		}
+	}
+	return nil
+}
+
+func (s *InMemoryStorage) ExportChat(ctx context.Context, chatID int64) (ChatExport, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	export := ChatExport{
+		ChatID:        chatID,
+		ChatState:     s.chatStates[chatID].State,
+		Language:      s.languages[chatID],
+		City:          s.catalog[s.cities[chatID]].Name,
+		Reminders:     []Reminder{},
+		Notifications: []Reminder{},
+	}
+
+	if token, ok := s.tokens[chatID]; ok {
+		export.Spotify = &LinkedAccount{TokenType: token.TokenType, TokenExpiry: token.Expiry}
+	}
+	_, export.CalendarFeed = s.calendars[chatID]
+
+	for _, authState := range s.states {
+		if authState.ChatID == chatID {
+			export.PendingAuth++
+		}
+	}
+
+	for _, r := range s.filterReminders(func(r Reminder) bool { return r.ChatID == chatID }) {
+		if r.SentAt != nil {
+			export.Notifications = append(export.Notifications, r)
+		} else {
+			export.Reminders = append(export.Reminders, r)
+		}
+	}
+
+	return export, nil
+}
@@ -0,0 +1,269 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// migrationsFS миграции вида 0001_name.up.sql / 0001_name.down.sql, вшитые в бинарник.
+// У sqlite своя история миграций, потому что схема в ней описывается другими типами.
+// В migrations/data лежат данные на переносимом sql, их подключают миграции обеих историй
+//
+//go:embed migrations/*.sql migrations/sqlite/*.sql migrations/data/*.sql
+var migrationsFS embed.FS
+
+const (
+	postgresMigrationsDir = "migrations"
+	sqliteMigrationsDir   = "migrations/sqlite"
+
+	// includeDirective строка миграции вида `\i data/file.sql`, как в psql, заменяется содержимым
+	// файла. Путь считается от каталога migrations
+	includeDirective = `\i `
+)
+
+// migrationsLockID ключ advisory lock, чтобы несколько запущенных ботов не применяли миграции одновременно
+const migrationsLockID = 7_041_202_410
+
+type migration struct {
+	version int
+	name    string
+	up      string
+	down    string
+}
+
+func loadMigrations(dir string) ([]migration, error) {
+	files, err := fs.Glob(migrationsFS, path.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*migration{}
+	for _, file := range files {
+		base := path.Base(file)
+
+		name, direction, ok := strings.Cut(strings.TrimSuffix(base, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migration %s: expected name.up.sql or name.down.sql", base)
+		}
+
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: expected positive version prefix", base)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{version: version, name: name}
+			byVersion[version] = m
+		} else if m.name != name {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", m.name, name)
+		}
+
+		body, err := readMigration(file)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", base, err)
+		}
+		if direction == "up" {
+			m.up = body
+		} else {
+			m.down = body
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, fmt.Errorf("migration %s has no up file", m.name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+
+	return migrations, nil
+}
+
+// readMigration читает файл миграции и подставляет в него подключённые файлы.
+// Подключённые файлы сами ничего не подключают, чтобы не разбираться с циклами
+func readMigration(file string) (string, error) {
+	body, err := migrationsFS.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(string(body), "\n")
+	for i, line := range lines {
+		included, ok := strings.CutPrefix(strings.TrimSpace(line), includeDirective)
+		if !ok {
+			continue
+		}
+
+		data, err := migrationsFS.ReadFile(path.Join("migrations", strings.TrimSpace(included)))
+		if err != nil {
+			return "", fmt.Errorf("failed to include %s: %w", included, err)
+		}
+		lines[i] = string(data)
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// Migrate применяет все ещё не применённые миграции, каждую в своей транзакции
+func (s *PostgresStorage) Migrate(ctx context.Context) error {
+	migrations, err := loadMigrations(postgresMigrationsDir)
+	if err != nil {
+		return err
+	}
+
+	return s.withMigrationsLock(ctx, func(conn *sql.Conn) error {
+		return applyMigrations(ctx, conn, migrations)
+	})
+}
+
+// Rollback откатывает steps последних применённых миграций
+func (s *PostgresStorage) Rollback(ctx context.Context, steps int) error {
+	migrations, err := loadMigrations(postgresMigrationsDir)
+	if err != nil {
+		return err
+	}
+
+	return s.withMigrationsLock(ctx, func(conn *sql.Conn) error {
+		return rollbackMigrations(ctx, conn, migrations, steps)
+	})
+}
+
+// withMigrationsLock держит advisory lock на отдельном соединении: блокировка сессионная,
+// поэтому и миграции, и снятие блокировки должны идти через то же соединение
+func (s *PostgresStorage) withMigrationsLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationsLockID); err != nil {
+		return fmt.Errorf("failed to acquire migrations lock: %w", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, migrationsLockID); err != nil {
+			log.Printf("Failed to release migrations lock: %v", err)
+		}
+	}()
+
+	return fn(conn)
+}
+
+func applyMigrations(ctx context.Context, conn *sql.Conn, migrations []migration) error {
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	known := map[int]bool{}
+	for _, m := range migrations {
+		known[m.version] = true
+		if applied[m.version] {
+			continue
+		}
+
+		if err := runMigration(ctx, conn, m.up, `
+			INSERT INTO schema_migrations (version, name) VALUES ($1, $2)
+		`, m.version, m.name); err != nil {
+			return fmt.Errorf("migration %s: %w", m.name, err)
+		}
+		log.Printf("Applied migration %s", m.name)
+	}
+
+	for version := range applied {
+		if !known[version] {
+			log.Printf("Database has migration %d unknown to this build", version)
+		}
+	}
+
+	return nil
+}
+
+func rollbackMigrations(ctx context.Context, conn *sql.Conn, migrations []migration, steps int) error {
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+		m := migrations[i]
+		if !applied[m.version] {
+			continue
+		}
+		if m.down == "" {
+			return fmt.Errorf("migration %s has no down file", m.name)
+		}
+
+		if err := runMigration(ctx, conn, m.down, `
+			DELETE FROM schema_migrations WHERE version = $1
+		`, m.version); err != nil {
+			return fmt.Errorf("rollback of %s: %w", m.name, err)
+		}
+		log.Printf("Rolled back migration %s", m.name)
+		steps--
+	}
+
+	return nil
+}
+
+// appliedMigrations создаёт schema_migrations, если её ещё нет, и возвращает применённые версии.
+// DDL написан так, чтобы подходить и для postgres, и для sqlite
+func appliedMigrations(ctx context.Context, conn *sql.Conn) (map[int]bool, error) {
+	_, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+		  version INTEGER PRIMARY KEY,
+		  name TEXT NOT NULL,
+		  applied_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	rows, err := conn.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]bool{}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+
+	return applied, rows.Err()
+}
+
+// runMigration выполняет sql миграции и запись в schema_migrations в одной транзакции
+func runMigration(ctx context.Context, conn *sql.Conn, body, record string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, body); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
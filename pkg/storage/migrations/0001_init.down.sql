@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS token;
+DROP TABLE IF EXISTS chat;
+DROP TABLE IF EXISTS auth_state;
+DROP TABLE IF EXISTS city;
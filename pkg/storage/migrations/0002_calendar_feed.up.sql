@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS calendar_feed (
+  chat_id BIGINT PRIMARY KEY REFERENCES chat(chat_id) ON DELETE CASCADE,
+  token TEXT NOT NULL UNIQUE
+);
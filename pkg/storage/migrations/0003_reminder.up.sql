@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS reminder (
+  id BIGSERIAL PRIMARY KEY,
+  chat_id BIGINT NOT NULL REFERENCES chat(chat_id) ON DELETE CASCADE,
+  concert_id INTEGER NOT NULL,
+  concert_name TEXT NOT NULL,
+  concert_url TEXT NOT NULL,
+  starts_at TIMESTAMPTZ NOT NULL,
+  utc_offset INTEGER NOT NULL DEFAULT 0,
+  remind_at TIMESTAMPTZ NOT NULL,
+  UNIQUE (chat_id, concert_id, remind_at)
+);
+
+CREATE INDEX IF NOT EXISTS reminder_remind_at_idx ON reminder (remind_at);
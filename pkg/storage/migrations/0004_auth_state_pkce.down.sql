@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS auth_state_chat_id_idx;
+
+ALTER TABLE auth_state DROP COLUMN IF EXISTS code_verifier;
+ALTER TABLE auth_state DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,4 @@
+ALTER TABLE auth_state ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE auth_state ADD COLUMN IF NOT EXISTS code_verifier TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS auth_state_chat_id_idx ON auth_state (chat_id);
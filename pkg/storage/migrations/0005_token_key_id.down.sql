@@ -0,0 +1,2 @@
+-- токены, зашифрованные после этой миграции, без key_id прочитать не получится
+ALTER TABLE token DROP COLUMN IF EXISTS key_id;
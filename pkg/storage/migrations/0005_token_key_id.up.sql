@@ -0,0 +1 @@
+ALTER TABLE token ADD COLUMN IF NOT EXISTS key_id TEXT NOT NULL DEFAULT '';
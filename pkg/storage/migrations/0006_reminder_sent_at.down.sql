@@ -0,0 +1,3 @@
+DELETE FROM reminder WHERE sent_at IS NOT NULL;
+
+ALTER TABLE reminder DROP COLUMN IF EXISTS sent_at;
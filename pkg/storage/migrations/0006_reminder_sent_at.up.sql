@@ -0,0 +1 @@
+ALTER TABLE reminder ADD COLUMN IF NOT EXISTS sent_at TIMESTAMPTZ;
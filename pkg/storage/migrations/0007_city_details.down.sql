@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS city_lower_name_idx;
+DROP TABLE IF EXISTS city_alias;
+
+ALTER TABLE city DROP COLUMN IF EXISTS population;
+ALTER TABLE city DROP COLUMN IF EXISTS timezone;
+ALTER TABLE city DROP COLUMN IF EXISTS longitude;
+ALTER TABLE city DROP COLUMN IF EXISTS latitude;
+ALTER TABLE city DROP COLUMN IF EXISTS timepad_name;
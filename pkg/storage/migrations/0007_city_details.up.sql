@@ -0,0 +1,16 @@
+ALTER TABLE city ADD COLUMN IF NOT EXISTS timepad_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE city ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE city ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE city ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT 'Europe/Moscow';
+ALTER TABLE city ADD COLUMN IF NOT EXISTS population INTEGER NOT NULL DEFAULT 0;
+
+UPDATE city SET timepad_name = city_name WHERE timepad_name = '';
+
+-- альтернативные написания хранятся в нижнем регистре, поиск идёт по ним и по lower(city_name)
+CREATE TABLE IF NOT EXISTS city_alias (
+  alias TEXT PRIMARY KEY,
+  city_id INTEGER NOT NULL REFERENCES city(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS city_alias_city_id_idx ON city_alias (city_id);
+CREATE INDEX IF NOT EXISTS city_lower_name_idx ON city (lower(city_name));
@@ -0,0 +1,12 @@
+ALTER TABLE chat DROP COLUMN state_updated_at;
+ALTER TABLE chat DROP COLUMN state_payload;
+
+ALTER TABLE chat ALTER COLUMN chat_state DROP DEFAULT;
+ALTER TABLE chat ALTER COLUMN chat_state TYPE INTEGER USING (
+  CASE chat_state
+    WHEN 'waiting_for_city' THEN 1
+    WHEN 'waiting_for_auth' THEN 2
+    ELSE 0
+  END
+);
+ALTER TABLE chat ALTER COLUMN chat_state SET DEFAULT 0;
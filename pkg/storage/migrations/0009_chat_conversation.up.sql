@@ -0,0 +1,16 @@
+ALTER TABLE chat ALTER COLUMN chat_state DROP DEFAULT;
+ALTER TABLE chat ALTER COLUMN chat_state TYPE TEXT USING (
+  CASE chat_state
+    WHEN 1 THEN 'waiting_for_city'
+    WHEN 2 THEN 'waiting_for_auth'
+    ELSE 'idle'
+  END
+);
+ALTER TABLE chat ALTER COLUMN chat_state SET DEFAULT 'idle';
+
+ALTER TABLE chat ADD COLUMN state_payload JSONB;
+ALTER TABLE chat ADD COLUMN state_updated_at TIMESTAMPTZ;
+
+-- у существующих чатов отсчёт таймаута состояния начинается с обновления, иначе ожидание города
+-- или авторизации считалось бы истёкшим на первом же сообщении
+UPDATE chat SET state_updated_at = now();
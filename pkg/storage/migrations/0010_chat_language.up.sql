@@ -0,0 +1 @@
+ALTER TABLE chat ADD COLUMN language TEXT;
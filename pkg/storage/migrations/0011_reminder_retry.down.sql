@@ -0,0 +1,2 @@
+ALTER TABLE reminder DROP COLUMN IF EXISTS retry_at;
+ALTER TABLE reminder DROP COLUMN IF EXISTS attempts;
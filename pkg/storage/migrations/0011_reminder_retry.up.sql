@@ -0,0 +1,2 @@
+ALTER TABLE reminder ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE reminder ADD COLUMN IF NOT EXISTS retry_at TIMESTAMPTZ;
@@ -0,0 +1 @@
+ALTER TABLE chat DROP COLUMN IF EXISTS last_active_at;
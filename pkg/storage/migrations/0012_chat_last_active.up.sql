@@ -0,0 +1,4 @@
+ALTER TABLE chat ADD COLUMN IF NOT EXISTS last_active_at TIMESTAMPTZ;
+
+-- уже зарегистрированные чаты считаются активными с момента обновления
+UPDATE chat SET last_active_at = now();
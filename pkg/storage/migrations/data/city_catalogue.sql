@@ -0,0 +1,359 @@
+-- города России от 100 тысяч жителей по переписи 2021 года и региональные центры меньше этого порога;
+-- timepad_name совпадает с названием, пока timepad не называет город иначе
+INSERT INTO city (city_name, timepad_name, latitude, longitude, timezone, population) VALUES
+  ('Москва', 'Москва', 55.7558, 37.6173, 'Europe/Moscow', 13010112),
+  ('Санкт-Петербург', 'Санкт-Петербург', 59.9386, 30.3141, 'Europe/Moscow', 5601911),
+  ('Новосибирск', 'Новосибирск', 55.0084, 82.9357, 'Asia/Novosibirsk', 1633595),
+  ('Екатеринбург', 'Екатеринбург', 56.8389, 60.6057, 'Asia/Yekaterinburg', 1544376),
+  ('Казань', 'Казань', 55.7963, 49.1088, 'Europe/Moscow', 1308660),
+  ('Нижний Новгород', 'Нижний Новгород', 56.3269, 44.0059, 'Europe/Moscow', 1249861),
+  ('Челябинск', 'Челябинск', 55.1644, 61.4368, 'Asia/Yekaterinburg', 1189525),
+  ('Красноярск', 'Красноярск', 56.0153, 92.8932, 'Asia/Krasnoyarsk', 1187771),
+  ('Самара', 'Самара', 53.1959, 50.1002, 'Europe/Samara', 1173299),
+  ('Уфа', 'Уфа', 54.7388, 55.9721, 'Asia/Yekaterinburg', 1144809),
+  ('Ростов-на-Дону', 'Ростов-на-Дону', 47.2357, 39.7015, 'Europe/Moscow', 1142162),
+  ('Омск', 'Омск', 54.9885, 73.3242, 'Asia/Omsk', 1125695),
+  ('Воронеж', 'Воронеж', 51.6615, 39.2003, 'Europe/Moscow', 1057681),
+  ('Пермь', 'Пермь', 58.0105, 56.2502, 'Asia/Yekaterinburg', 1034002),
+  ('Волгоград', 'Волгоград', 48.7080, 44.5133, 'Europe/Volgograd', 1028036),
+  ('Краснодар', 'Краснодар', 45.0355, 38.9753, 'Europe/Moscow', 948827),
+  ('Саратов', 'Саратов', 51.5331, 46.0342, 'Europe/Saratov', 901361),
+  ('Тюмень', 'Тюмень', 57.1522, 65.5272, 'Asia/Yekaterinburg', 847488),
+  ('Тольятти', 'Тольятти', 53.5078, 49.4204, 'Europe/Samara', 684709),
+  ('Ижевск', 'Ижевск', 56.8526, 53.2045, 'Europe/Samara', 646468),
+  ('Барнаул', 'Барнаул', 53.3548, 83.7698, 'Asia/Barnaul', 630877),
+  ('Махачкала', 'Махачкала', 42.9849, 47.5047, 'Europe/Moscow', 623254),
+  ('Хабаровск', 'Хабаровск', 48.4827, 135.0838, 'Asia/Vladivostok', 617441),
+  ('Ульяновск', 'Ульяновск', 54.3142, 48.4031, 'Europe/Ulyanovsk', 617352),
+  ('Иркутск', 'Иркутск', 52.2870, 104.3050, 'Asia/Irkutsk', 617264),
+  ('Владивосток', 'Владивосток', 43.1155, 131.8855, 'Asia/Vladivostok', 603519),
+  ('Ярославль', 'Ярославль', 57.6261, 39.8845, 'Europe/Moscow', 577279),
+  ('Оренбург', 'Оренбург', 51.7682, 55.0970, 'Asia/Yekaterinburg', 572188),
+  ('Томск', 'Томск', 56.4847, 84.9482, 'Asia/Tomsk', 568508),
+  ('Кемерово', 'Кемерово', 55.3547, 86.0873, 'Asia/Novokuznetsk', 557119),
+  ('Набережные Челны', 'Набережные Челны', 55.7436, 52.3958, 'Europe/Moscow', 548434),
+  ('Ставрополь', 'Ставрополь', 45.0445, 41.9691, 'Europe/Moscow', 547443),
+  ('Новокузнецк', 'Новокузнецк', 53.7596, 87.1216, 'Asia/Novokuznetsk', 537480),
+  ('Рязань', 'Рязань', 54.6269, 39.6916, 'Europe/Moscow', 527927),
+  ('Балашиха', 'Балашиха', 55.7963, 37.9382, 'Europe/Moscow', 520962),
+  ('Пенза', 'Пенза', 53.1959, 45.0183, 'Europe/Moscow', 504151),
+  ('Липецк', 'Липецк', 52.6088, 39.5992, 'Europe/Moscow', 503216),
+  ('Чебоксары', 'Чебоксары', 56.1439, 47.2489, 'Europe/Moscow', 499662),
+  ('Калининград', 'Калининград', 54.7104, 20.4522, 'Europe/Kaliningrad', 489359),
+  ('Севастополь', 'Севастополь', 44.6167, 33.5254, 'Europe/Simferopol', 479765),
+  ('Тула', 'Тула', 54.1931, 37.6173, 'Europe/Moscow', 473622),
+  ('Астрахань', 'Астрахань', 46.3479, 48.0336, 'Europe/Astrakhan', 468215),
+  ('Киров', 'Киров', 58.6036, 49.6680, 'Europe/Kirov', 468212),
+  ('Сочи', 'Сочи', 43.5855, 39.7231, 'Europe/Moscow', 466078),
+  ('Курск', 'Курск', 51.7304, 36.1926, 'Europe/Moscow', 440052),
+  ('Улан-Удэ', 'Улан-Удэ', 51.8335, 107.5841, 'Asia/Irkutsk', 437565),
+  ('Тверь', 'Тверь', 56.8587, 35.9176, 'Europe/Moscow', 416219),
+  ('Магнитогорск', 'Магнитогорск', 53.4072, 58.9791, 'Asia/Yekaterinburg', 410594),
+  ('Сургут', 'Сургут', 61.2540, 73.3962, 'Asia/Yekaterinburg', 396443),
+  ('Брянск', 'Брянск', 53.2434, 34.3654, 'Europe/Moscow', 376025),
+  ('Иваново', 'Иваново', 57.0004, 40.9739, 'Europe/Moscow', 361641),
+  ('Якутск', 'Якутск', 62.0355, 129.6755, 'Asia/Yakutsk', 355443),
+  ('Чита', 'Чита', 52.0339, 113.4994, 'Asia/Chita', 350861),
+  ('Владимир', 'Владимир', 56.1291, 40.4066, 'Europe/Moscow', 349951),
+  ('Новороссийск', 'Новороссийск', 44.7235, 37.7686, 'Europe/Moscow', 341107),
+  ('Симферополь', 'Симферополь', 44.9521, 34.1024, 'Europe/Simferopol', 340540),
+  ('Белгород', 'Белгород', 50.5955, 36.5873, 'Europe/Moscow', 339978),
+  ('Нижний Тагил', 'Нижний Тагил', 57.9101, 59.9813, 'Asia/Yekaterinburg', 338356),
+  ('Калуга', 'Калуга', 54.5138, 36.2612, 'Europe/Moscow', 337058),
+  ('Грозный', 'Грозный', 43.3178, 45.6949, 'Europe/Moscow', 324602),
+  ('Волжский', 'Волжский', 48.7858, 44.7797, 'Europe/Volgograd', 321479),
+  ('Смоленск', 'Смоленск', 54.7826, 32.0453, 'Europe/Moscow', 316570),
+  ('Саранск', 'Саранск', 54.1874, 45.1839, 'Europe/Moscow', 314789),
+  ('Вологда', 'Вологда', 59.2205, 39.8915, 'Europe/Moscow', 310302),
+  ('Курган', 'Курган', 55.4410, 65.3411, 'Asia/Yekaterinburg', 309285),
+  ('Подольск', 'Подольск', 55.4311, 37.5447, 'Europe/Moscow', 308130),
+  ('Владикавказ', 'Владикавказ', 43.0205, 44.6819, 'Europe/Moscow', 306978),
+  ('Череповец', 'Череповец', 59.1333, 37.9000, 'Europe/Moscow', 301957),
+  ('Архангельск', 'Архангельск', 64.5393, 40.5170, 'Europe/Moscow', 301199),
+  ('Орёл', 'Орёл', 52.9671, 36.0696, 'Europe/Moscow', 301014),
+  ('Нижневартовск', 'Нижневартовск', 60.9397, 76.5696, 'Asia/Yekaterinburg', 283256),
+  ('Йошкар-Ола', 'Йошкар-Ола', 56.6344, 47.8999, 'Europe/Moscow', 281248),
+  ('Петрозаводск', 'Петрозаводск', 61.7891, 34.3596, 'Europe/Moscow', 279190),
+  ('Стерлитамак', 'Стерлитамак', 53.6301, 55.9317, 'Asia/Yekaterinburg', 277410),
+  ('Мурманск', 'Мурманск', 68.9707, 33.0749, 'Europe/Moscow', 270384),
+  ('Кострома', 'Кострома', 57.7677, 40.9264, 'Europe/Moscow', 267937),
+  ('Тамбов', 'Тамбов', 52.7212, 41.4523, 'Europe/Moscow', 261803),
+  ('Химки', 'Химки', 55.8887, 37.4300, 'Europe/Moscow', 259550),
+  ('Таганрог', 'Таганрог', 47.2362, 38.8969, 'Europe/Moscow', 248643),
+  ('Нальчик', 'Нальчик', 43.4846, 43.6071, 'Europe/Moscow', 247054),
+  ('Нижнекамск', 'Нижнекамск', 55.6366, 51.8245, 'Europe/Moscow', 241479),
+  ('Благовещенск', 'Благовещенск', 50.2907, 127.5272, 'Asia/Yakutsk', 241437),
+  ('Комсомольск-на-Амуре', 'Комсомольск-на-Амуре', 50.5503, 137.0080, 'Asia/Vladivostok', 240554),
+  ('Мытищи', 'Мытищи', 55.9116, 37.7308, 'Europe/Moscow', 235504),
+  ('Братск', 'Братск', 56.1514, 101.6342, 'Asia/Irkutsk', 225520),
+  ('Великий Новгород', 'Великий Новгород', 58.5213, 31.2710, 'Europe/Moscow', 225019),
+  ('Королёв', 'Королёв', 55.9162, 37.8545, 'Europe/Moscow', 224348),
+  ('Старый Оскол', 'Старый Оскол', 51.2967, 37.8417, 'Europe/Moscow', 224236),
+  ('Шахты', 'Шахты', 47.7085, 40.2160, 'Europe/Moscow', 224164),
+  ('Ангарск', 'Ангарск', 52.5448, 103.8885, 'Asia/Irkutsk', 221296),
+  ('Сыктывкар', 'Сыктывкар', 61.6688, 50.8364, 'Europe/Moscow', 220580),
+  ('Дзержинск', 'Дзержинск', 56.2376, 43.4599, 'Europe/Moscow', 218166),
+  ('Люберцы', 'Люберцы', 55.6783, 37.8938, 'Europe/Moscow', 205295),
+  ('Энгельс', 'Энгельс', 51.4853, 46.1267, 'Europe/Saratov', 202419),
+  ('Орск', 'Орск', 51.2293, 58.4752, 'Asia/Yekaterinburg', 194342),
+  ('Прокопьевск', 'Прокопьевск', 53.9059, 86.7190, 'Asia/Novokuznetsk', 187877),
+  ('Псков', 'Псков', 57.8136, 28.3496, 'Europe/Moscow', 187504),
+  ('Абакан', 'Абакан', 53.7212, 91.4424, 'Asia/Krasnoyarsk', 186797),
+  ('Армавир', 'Армавир', 44.9892, 41.1234, 'Europe/Moscow', 186102),
+  ('Бийск', 'Бийск', 52.5414, 85.2196, 'Asia/Barnaul', 184009),
+  ('Балаково', 'Балаково', 52.0278, 47.8007, 'Europe/Saratov', 183264),
+  ('Южно-Сахалинск', 'Южно-Сахалинск', 46.9591, 142.7380, 'Asia/Sakhalin', 181728),
+  ('Рыбинск', 'Рыбинск', 58.0485, 38.8584, 'Europe/Moscow', 178052),
+  ('Красногорск', 'Красногорск', 55.8204, 37.3302, 'Europe/Moscow', 175812),
+  ('Норильск', 'Норильск', 69.3498, 88.2010, 'Asia/Krasnoyarsk', 175301),
+  ('Уссурийск', 'Уссурийск', 43.7971, 131.9518, 'Asia/Vladivostok', 173939),
+  ('Волгодонск', 'Волгодонск', 47.5165, 42.1985, 'Europe/Moscow', 170621),
+  ('Новочеркасск', 'Новочеркасск', 47.4114, 40.1043, 'Europe/Moscow', 165979),
+  ('Петропавловск-Камчатский', 'Петропавловск-Камчатский', 53.0370, 158.6559, 'Asia/Kamchatka', 164900),
+  ('Каменск-Уральский', 'Каменск-Уральский', 56.4149, 61.9189, 'Asia/Yekaterinburg', 161138),
+  ('Златоуст', 'Златоуст', 55.1711, 59.6508, 'Asia/Yekaterinburg', 161122),
+  ('Сызрань', 'Сызрань', 53.1557, 48.4745, 'Europe/Samara', 159200),
+  ('Альметьевск', 'Альметьевск', 54.9013, 52.2970, 'Europe/Moscow', 157340),
+  ('Хасавюрт', 'Хасавюрт', 43.2509, 46.5879, 'Europe/Moscow', 157280),
+  ('Северодвинск', 'Северодвинск', 64.5582, 39.8296, 'Europe/Moscow', 157213),
+  ('Электросталь', 'Электросталь', 55.7847, 38.4447, 'Europe/Moscow', 155324),
+  ('Керчь', 'Керчь', 45.3562, 36.4674, 'Europe/Simferopol', 151548),
+  ('Салават', 'Салават', 53.3616, 55.9245, 'Asia/Yekaterinburg', 150105),
+  ('Копейск', 'Копейск', 55.1167, 61.6167, 'Asia/Yekaterinburg', 149806),
+  ('Миасс', 'Миасс', 55.0450, 60.1083, 'Asia/Yekaterinburg', 148137),
+  ('Пятигорск', 'Пятигорск', 44.0486, 43.0594, 'Europe/Moscow', 145448),
+  ('Майкоп', 'Майкоп', 44.6098, 40.1006, 'Europe/Moscow', 141970),
+  ('Одинцово', 'Одинцово', 55.6789, 37.2636, 'Europe/Moscow', 141438),
+  ('Домодедово', 'Домодедово', 55.4364, 37.7666, 'Europe/Moscow', 139132),
+  ('Рубцовск', 'Рубцовск', 51.5147, 81.2061, 'Asia/Barnaul', 139010),
+  ('Березники', 'Березники', 59.4091, 56.8204, 'Asia/Yekaterinburg', 138981),
+  ('Находка', 'Находка', 42.8240, 132.8927, 'Asia/Vladivostok', 137600),
+  ('Коломна', 'Коломна', 55.0794, 38.7783, 'Europe/Moscow', 136154),
+  ('Ковров', 'Ковров', 56.3636, 41.3119, 'Europe/Moscow', 134540),
+  ('Нефтекамск', 'Нефтекамск', 56.0886, 54.2482, 'Asia/Yekaterinburg', 132741),
+  ('Батайск', 'Батайск', 47.1383, 39.7448, 'Europe/Moscow', 130356),
+  ('Щёлково', 'Щёлково', 55.9233, 37.9858, 'Europe/Moscow', 129450),
+  ('Кисловодск', 'Кисловодск', 43.9133, 42.7207, 'Europe/Moscow', 128502),
+  ('Каспийск', 'Каспийск', 42.8817, 47.6383, 'Europe/Moscow', 128148),
+  ('Нефтеюганск', 'Нефтеюганск', 61.0998, 72.6035, 'Asia/Yekaterinburg', 127255),
+  ('Серпухов', 'Серпухов', 54.9158, 37.4111, 'Europe/Moscow', 126496),
+  ('Обнинск', 'Обнинск', 55.0968, 36.6101, 'Europe/Moscow', 126244),
+  ('Новочебоксарск', 'Новочебоксарск', 56.1095, 47.4791, 'Europe/Moscow', 126072),
+  ('Кызыл', 'Кызыл', 51.7191, 94.4378, 'Asia/Krasnoyarsk', 125728),
+  ('Назрань', 'Назрань', 43.2257, 44.7645, 'Europe/Moscow', 125234),
+  ('Дербент', 'Дербент', 42.0578, 48.2887, 'Europe/Moscow', 125207),
+  ('Новомосковск', 'Новомосковск', 54.0105, 38.2846, 'Europe/Moscow', 122345),
+  ('Раменское', 'Раменское', 55.5669, 38.2303, 'Europe/Moscow', 121219),
+  ('Первоуральск', 'Первоуральск', 56.9080, 59.9429, 'Asia/Yekaterinburg', 119237),
+  ('Орехово-Зуево', 'Орехово-Зуево', 55.8067, 38.9618, 'Europe/Moscow', 118822),
+  ('Невинномысск', 'Невинномысск', 44.6334, 41.9447, 'Europe/Moscow', 115196),
+  ('Ессентуки', 'Ессентуки', 44.0444, 42.8640, 'Europe/Moscow', 114813),
+  ('Октябрьский', 'Октябрьский', 54.4815, 53.4656, 'Asia/Yekaterinburg', 114302),
+  ('Долгопрудный', 'Долгопрудный', 55.9386, 37.5103, 'Europe/Moscow', 114146),
+  ('Димитровград', 'Димитровград', 54.2167, 49.6167, 'Europe/Ulyanovsk', 113150),
+  ('Черкесск', 'Черкесск', 44.2268, 42.0469, 'Europe/Moscow', 112012),
+  ('Северск', 'Северск', 56.6031, 84.8809, 'Asia/Tomsk', 110186),
+  ('Артём', 'Артём', 43.3500, 132.1897, 'Asia/Vladivostok', 110136),
+  ('Пушкино', 'Пушкино', 56.0104, 37.8471, 'Europe/Moscow', 107806),
+  ('Новый Уренгой', 'Новый Уренгой', 66.0833, 76.6333, 'Asia/Yekaterinburg', 107795),
+  ('Реутов', 'Реутов', 55.7606, 37.8550, 'Europe/Moscow', 107211),
+  ('Жуковский', 'Жуковский', 55.5953, 38.1203, 'Europe/Moscow', 107113),
+  ('Камышин', 'Камышин', 50.0833, 45.4000, 'Europe/Volgograd', 107000),
+  ('Муром', 'Муром', 55.5793, 42.0528, 'Europe/Moscow', 106953),
+  ('Ноябрьск', 'Ноябрьск', 63.2018, 75.4510, 'Asia/Yekaterinburg', 106911),
+  ('Новошахтинск', 'Новошахтинск', 47.7579, 39.9364, 'Europe/Moscow', 106030),
+  ('Евпатория', 'Евпатория', 45.1904, 33.3669, 'Europe/Simferopol', 105915),
+  ('Ачинск', 'Ачинск', 56.2694, 90.4993, 'Asia/Krasnoyarsk', 105259),
+  ('Елец', 'Елец', 52.6208, 38.5031, 'Europe/Moscow', 104975),
+  ('Арзамас', 'Арзамас', 55.3946, 43.8408, 'Europe/Moscow', 104012),
+  ('Бердск', 'Бердск', 54.7580, 83.1070, 'Asia/Novosibirsk', 103449),
+  ('Тобольск', 'Тобольск', 58.1981, 68.2645, 'Asia/Yekaterinburg', 101512),
+  ('Ханты-Мансийск', 'Ханты-Мансийск', 61.0042, 69.0019, 'Asia/Yekaterinburg', 101466),
+  ('Элиста', 'Элиста', 46.3078, 44.2558, 'Europe/Moscow', 100116),
+  ('Магадан', 'Магадан', 59.5682, 150.8085, 'Asia/Magadan', 90757),
+  ('Биробиджан', 'Биробиджан', 48.7946, 132.9216, 'Asia/Vladivostok', 69983),
+  ('Горно-Алтайск', 'Горно-Алтайск', 51.9581, 85.9603, 'Asia/Barnaul', 64521),
+  ('Салехард', 'Салехард', 66.5300, 66.6019, 'Asia/Yekaterinburg', 51186),
+  ('Нарьян-Мар', 'Нарьян-Мар', 67.6380, 53.0069, 'Europe/Moscow', 25536),
+  ('Анадырь', 'Анадырь', 64.7337, 177.4968, 'Asia/Anadyr', 15468),
+  ('Магас', 'Магас', 43.1688, 44.8132, 'Europe/Moscow', 15279)
+ON CONFLICT (city_name) DO UPDATE
+SET timepad_name = EXCLUDED.timepad_name,
+    latitude = EXCLUDED.latitude,
+    longitude = EXCLUDED.longitude,
+    timezone = EXCLUDED.timezone,
+    population = EXCLUDED.population;
+
+WITH a (alias, city_name) AS (VALUES
+  ('мск', 'Москва'),
+  ('msk', 'Москва'),
+  ('moscow', 'Москва'),
+  ('moskva', 'Москва'),
+  ('спб', 'Санкт-Петербург'),
+  ('питер', 'Санкт-Петербург'),
+  ('петербург', 'Санкт-Петербург'),
+  ('санкт петербург', 'Санкт-Петербург'),
+  ('saint petersburg', 'Санкт-Петербург'),
+  ('st petersburg', 'Санкт-Петербург'),
+  ('spb', 'Санкт-Петербург'),
+  ('piter', 'Санкт-Петербург'),
+  ('нск', 'Новосибирск'),
+  ('новосиб', 'Новосибирск'),
+  ('novosibirsk', 'Новосибирск'),
+  ('екб', 'Екатеринбург'),
+  ('ебург', 'Екатеринбург'),
+  ('yekaterinburg', 'Екатеринбург'),
+  ('ekaterinburg', 'Екатеринбург'),
+  ('kazan', 'Казань'),
+  ('нн', 'Нижний Новгород'),
+  ('нижний', 'Нижний Новгород'),
+  ('nizhny novgorod', 'Нижний Новгород'),
+  ('челяба', 'Челябинск'),
+  ('chelyabinsk', 'Челябинск'),
+  ('krasnoyarsk', 'Красноярск'),
+  ('samara', 'Самара'),
+  ('ufa', 'Уфа'),
+  ('ростов', 'Ростов-на-Дону'),
+  ('ростов на дону', 'Ростов-на-Дону'),
+  ('rostov-on-don', 'Ростов-на-Дону'),
+  ('rostov', 'Ростов-на-Дону'),
+  ('omsk', 'Омск'),
+  ('крд', 'Краснодар'),
+  ('krasnodar', 'Краснодар'),
+  ('voronezh', 'Воронеж'),
+  ('perm', 'Пермь'),
+  ('volgograd', 'Волгоград'),
+  ('saratov', 'Саратов'),
+  ('tyumen', 'Тюмень'),
+  ('тлт', 'Тольятти'),
+  ('togliatti', 'Тольятти'),
+  ('tolyatti', 'Тольятти'),
+  ('izhevsk', 'Ижевск'),
+  ('barnaul', 'Барнаул'),
+  ('ulyanovsk', 'Ульяновск'),
+  ('irkutsk', 'Иркутск'),
+  ('khabarovsk', 'Хабаровск'),
+  ('makhachkala', 'Махачкала'),
+  ('yaroslavl', 'Ярославль'),
+  ('vladivostok', 'Владивосток'),
+  ('orenburg', 'Оренбург'),
+  ('tomsk', 'Томск'),
+  ('kemerovo', 'Кемерово'),
+  ('novokuznetsk', 'Новокузнецк'),
+  ('ryazan', 'Рязань'),
+  ('челны', 'Набережные Челны'),
+  ('naberezhnye chelny', 'Набережные Челны'),
+  ('astrakhan', 'Астрахань'),
+  ('penza', 'Пенза'),
+  ('kirov', 'Киров'),
+  ('lipetsk', 'Липецк'),
+  ('cheboksary', 'Чебоксары'),
+  ('kaliningrad', 'Калининград'),
+  ('tula', 'Тула'),
+  ('kursk', 'Курск'),
+  ('stavropol', 'Ставрополь'),
+  ('sochi', 'Сочи'),
+  ('улан удэ', 'Улан-Удэ'),
+  ('ulan-ude', 'Улан-Удэ'),
+  ('tver', 'Тверь'),
+  ('magnitogorsk', 'Магнитогорск'),
+  ('ivanovo', 'Иваново'),
+  ('bryansk', 'Брянск'),
+  ('belgorod', 'Белгород'),
+  ('surgut', 'Сургут'),
+  ('vladimir', 'Владимир'),
+  ('arkhangelsk', 'Архангельск'),
+  ('chita', 'Чита'),
+  ('kaluga', 'Калуга'),
+  ('smolensk', 'Смоленск'),
+  ('volzhsky', 'Волжский'),
+  ('yakutsk', 'Якутск'),
+  ('saransk', 'Саранск'),
+  ('cherepovets', 'Череповец'),
+  ('kurgan', 'Курган'),
+  ('vologda', 'Вологда'),
+  ('орел', 'Орёл'),
+  ('oryol', 'Орёл'),
+  ('orel', 'Орёл'),
+  ('vladikavkaz', 'Владикавказ'),
+  ('grozny', 'Грозный'),
+  ('murmansk', 'Мурманск'),
+  ('tambov', 'Тамбов'),
+  ('petrozavodsk', 'Петрозаводск'),
+  ('kostroma', 'Кострома'),
+  ('nizhnevartovsk', 'Нижневартовск'),
+  ('novorossiysk', 'Новороссийск'),
+  ('йошкар ола', 'Йошкар-Ола'),
+  ('yoshkar-ola', 'Йошкар-Ола'),
+  ('taganrog', 'Таганрог'),
+  ('syktyvkar', 'Сыктывкар'),
+  ('nalchik', 'Нальчик'),
+  ('новгород', 'Великий Новгород'),
+  ('veliky novgorod', 'Великий Новгород'),
+  ('blagoveshchensk', 'Благовещенск'),
+  ('pskov', 'Псков'),
+  ('abakan', 'Абакан'),
+  ('южно сахалинск', 'Южно-Сахалинск'),
+  ('yuzhno-sakhalinsk', 'Южно-Сахалинск'),
+  ('петропавловск камчатский', 'Петропавловск-Камчатский'),
+  ('petropavlovsk-kamchatsky', 'Петропавловск-Камчатский'),
+  ('pyatigorsk', 'Пятигорск'),
+  ('novy urengoy', 'Новый Уренгой'),
+  ('ханты мансийск', 'Ханты-Мансийск'),
+  ('khanty-mansiysk', 'Ханты-Мансийск'),
+  ('magadan', 'Магадан'),
+  ('balashikha', 'Балашиха'),
+  ('севас', 'Севастополь'),
+  ('sevastopol', 'Севастополь'),
+  ('simferopol', 'Симферополь'),
+  ('тагил', 'Нижний Тагил'),
+  ('nizhny tagil', 'Нижний Тагил'),
+  ('podolsk', 'Подольск'),
+  ('sterlitamak', 'Стерлитамак'),
+  ('khimki', 'Химки'),
+  ('nizhnekamsk', 'Нижнекамск'),
+  ('комсомольск', 'Комсомольск-на-Амуре'),
+  ('комсомольск на амуре', 'Комсомольск-на-Амуре'),
+  ('komsomolsk-on-amur', 'Комсомольск-на-Амуре'),
+  ('mytishchi', 'Мытищи'),
+  ('bratsk', 'Братск'),
+  ('оскол', 'Старый Оскол'),
+  ('stary oskol', 'Старый Оскол'),
+  ('королев', 'Королёв'),
+  ('korolyov', 'Королёв'),
+  ('shakhty', 'Шахты'),
+  ('angarsk', 'Ангарск'),
+  ('dzerzhinsk', 'Дзержинск'),
+  ('lyubertsy', 'Люберцы'),
+  ('engels', 'Энгельс'),
+  ('orsk', 'Орск'),
+  ('armavir', 'Армавир'),
+  ('biysk', 'Бийск'),
+  ('rybinsk', 'Рыбинск'),
+  ('norilsk', 'Норильск'),
+  ('ussuriysk', 'Уссурийск'),
+  ('каменск уральский', 'Каменск-Уральский'),
+  ('syzran', 'Сызрань'),
+  ('severodvinsk', 'Северодвинск'),
+  ('kerch', 'Керчь'),
+  ('maykop', 'Майкоп'),
+  ('nakhodka', 'Находка'),
+  ('щелково', 'Щёлково'),
+  ('kislovodsk', 'Кисловодск'),
+  ('obninsk', 'Обнинск'),
+  ('kyzyl', 'Кызыл'),
+  ('derbent', 'Дербент'),
+  ('орехово зуево', 'Орехово-Зуево'),
+  ('артем', 'Артём'),
+  ('evpatoria', 'Евпатория'),
+  ('elista', 'Элиста'),
+  ('горно алтайск', 'Горно-Алтайск'),
+  ('нарьян мар', 'Нарьян-Мар'),
+  ('anadyr', 'Анадырь')
+)
+INSERT INTO city_alias (alias, city_id)
+SELECT a.alias, c.id
+FROM a
+JOIN city c ON c.city_name = a.city_name
+WHERE true
+ON CONFLICT (alias) DO NOTHING;
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS reminder;
+DROP TABLE IF EXISTS calendar_feed;
+DROP TABLE IF EXISTS token;
+DROP TABLE IF EXISTS chat;
+DROP TABLE IF EXISTS auth_state;
+DROP TABLE IF EXISTS city_alias;
+DROP TABLE IF EXISTS city;
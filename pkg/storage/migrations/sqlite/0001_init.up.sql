@@ -0,0 +1,62 @@
+-- та же схема, что у postgres после миграции 0007, в типах sqlite.
+-- Время хранится строками в UTC, поэтому колонки объявлены как TIMESTAMP: так драйвер разбирает их обратно в time.Time
+CREATE TABLE city (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  city_name TEXT NOT NULL UNIQUE,
+  timepad_name TEXT NOT NULL DEFAULT '',
+  latitude REAL NOT NULL DEFAULT 0,
+  longitude REAL NOT NULL DEFAULT 0,
+  timezone TEXT NOT NULL DEFAULT 'Europe/Moscow',
+  population INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE city_alias (
+  alias TEXT PRIMARY KEY,
+  city_id INTEGER NOT NULL REFERENCES city(id) ON DELETE CASCADE
+);
+
+CREATE INDEX city_alias_city_id_idx ON city_alias (city_id);
+
+CREATE TABLE auth_state (
+  state TEXT PRIMARY KEY,
+  chat_id INTEGER NOT NULL,
+  code_verifier TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX auth_state_chat_id_idx ON auth_state (chat_id);
+
+CREATE TABLE chat (
+  chat_id INTEGER PRIMARY KEY,
+  chat_state INTEGER NOT NULL DEFAULT 0,
+  city_id INTEGER REFERENCES city(id)
+);
+
+CREATE TABLE token (
+  chat_id INTEGER PRIMARY KEY REFERENCES chat(chat_id) ON DELETE CASCADE,
+  access_token TEXT NOT NULL,
+  token_type TEXT NOT NULL,
+  refresh_token TEXT NOT NULL,
+  expiry TIMESTAMP NOT NULL,
+  key_id TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE calendar_feed (
+  chat_id INTEGER PRIMARY KEY REFERENCES chat(chat_id) ON DELETE CASCADE,
+  token TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE reminder (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  chat_id INTEGER NOT NULL REFERENCES chat(chat_id) ON DELETE CASCADE,
+  concert_id INTEGER NOT NULL,
+  concert_name TEXT NOT NULL,
+  concert_url TEXT NOT NULL,
+  starts_at TIMESTAMP NOT NULL,
+  utc_offset INTEGER NOT NULL DEFAULT 0,
+  remind_at TIMESTAMP NOT NULL,
+  sent_at TIMESTAMP,
+  UNIQUE (chat_id, concert_id, remind_at)
+);
+
+CREATE INDEX reminder_remind_at_idx ON reminder (remind_at);
@@ -0,0 +1,6 @@
+DELETE FROM city_alias;
+
+-- города, выбранные пользователями, и исходные три города остаются
+DELETE FROM city
+WHERE city_name NOT IN ('Москва', 'Санкт-Петербург', 'Казань')
+  AND id NOT IN (SELECT city_id FROM chat WHERE city_id IS NOT NULL);
@@ -0,0 +1,2 @@
+-- справочник общий для postgres и sqlite, см. data/
+\i data/city_catalogue.sql
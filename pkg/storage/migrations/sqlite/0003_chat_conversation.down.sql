@@ -0,0 +1,11 @@
+ALTER TABLE chat DROP COLUMN state_updated_at;
+ALTER TABLE chat DROP COLUMN state_payload;
+
+ALTER TABLE chat ADD COLUMN state INTEGER NOT NULL DEFAULT 0;
+UPDATE chat SET state = CASE chat_state
+  WHEN 'waiting_for_city' THEN 1
+  WHEN 'waiting_for_auth' THEN 2
+  ELSE 0
+END;
+ALTER TABLE chat DROP COLUMN chat_state;
+ALTER TABLE chat RENAME COLUMN state TO chat_state;
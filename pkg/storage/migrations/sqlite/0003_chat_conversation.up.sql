@@ -0,0 +1,15 @@
+ALTER TABLE chat ADD COLUMN state TEXT NOT NULL DEFAULT 'idle';
+UPDATE chat SET state = CASE chat_state
+  WHEN 1 THEN 'waiting_for_city'
+  WHEN 2 THEN 'waiting_for_auth'
+  ELSE 'idle'
+END;
+ALTER TABLE chat DROP COLUMN chat_state;
+ALTER TABLE chat RENAME COLUMN state TO chat_state;
+
+ALTER TABLE chat ADD COLUMN state_payload TEXT;
+ALTER TABLE chat ADD COLUMN state_updated_at TIMESTAMP;
+
+-- у существующих чатов отсчёт таймаута состояния начинается с обновления, иначе ожидание города
+-- или авторизации считалось бы истёкшим на первом же сообщении. Время в формате _time_format=sqlite
+UPDATE chat SET state_updated_at = strftime('%Y-%m-%d %H:%M:%S+00:00', 'now');
@@ -0,0 +1,2 @@
+ALTER TABLE reminder DROP COLUMN retry_at;
+ALTER TABLE reminder DROP COLUMN attempts;
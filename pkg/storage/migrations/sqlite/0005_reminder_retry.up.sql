@@ -0,0 +1,2 @@
+ALTER TABLE reminder ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE reminder ADD COLUMN retry_at TIMESTAMP;
@@ -0,0 +1 @@
+ALTER TABLE chat DROP COLUMN last_active_at;
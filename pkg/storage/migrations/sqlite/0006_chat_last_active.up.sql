@@ -0,0 +1,4 @@
+ALTER TABLE chat ADD COLUMN last_active_at TIMESTAMP;
+
+-- уже зарегистрированные чаты считаются активными с момента обновления, время в формате _time_format=sqlite
+UPDATE chat SET last_active_at = strftime('%Y-%m-%d %H:%M:%S+00:00', 'now');
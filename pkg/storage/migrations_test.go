@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadMigrationsIncludesSharedData(t *testing.T) {
+	postgres, err := loadMigrations(postgresMigrationsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlite, err := loadMigrations(sqliteMigrationsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, m := range append(postgres, sqlite...) {
+		if strings.Contains(m.up, includeDirective) || strings.Contains(m.down, includeDirective) {
+			t.Errorf("migration %s still has an include directive", m.name)
+		}
+	}
+
+	// справочник городов должен быть одинаковым в обеих историях миграций
+	byName := func(migrations []migration, name string) migration {
+		for _, m := range migrations {
+			if m.name == name {
+				return m
+			}
+		}
+		t.Fatalf("migration %s not found", name)
+		return migration{}
+	}
+
+	pg, lite := byName(postgres, "0008_city_catalogue"), byName(sqlite, "0002_city_catalogue")
+	if !strings.Contains(pg.up, "INSERT INTO city ") {
+		t.Errorf("migration %s doesn't insert cities", pg.name)
+	}
+	if pg.up != lite.up {
+		t.Errorf("migrations %s and %s differ", pg.name, lite.name)
+	}
+}
@@ -1,83 +1,187 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"strconv"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/shakareem/gigoseek/pkg/config"
 	"golang.org/x/oauth2"
 )
 
-type ChatState int
-
 type PostgresStorage struct {
 	db *sql.DB
+	// q соединение для запросов: сама база или транзакция, если хранилище получено из WithTx
+	q      querier
+	tx     *sql.Tx
+	cipher *TokenCipher
 }
 
-func NewPostgresStorage() (*PostgresStorage, error) {
+// NewPostgresStorage открывает базу из конфига. migrate=false оставляет схему как есть
+func NewPostgresStorage(ctx context.Context, migrate bool) (*PostgresStorage, error) {
 	cfg := config.Get().Database
 
-	psqlInfo := fmt.Sprintf(
-		"host=%s port= %d user=%s password=%s dbname=%s sslmode=disable",
-		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBname,
-	)
+	cipher, err := NewTokenCipher(config.Get().TokenEncryptionKeys)
+	if err != nil {
+		return nil, fmt.Errorf("token encryption: %w", err)
+	}
 
-	db, err := sql.Open("postgres", psqlInfo)
+	return OpenPostgresStorage(ctx, postgresDSN(cfg), cipher, optionsFromConfig(cfg, migrate))
+}
+
+// OpenPostgresStorage подключается к базе по dsn, дожидается её доступности и применяет миграции, если не задан opts.SkipMigrations
+func OpenPostgresStorage(ctx context.Context, dsn string, cipher *TokenCipher, opts Options) (*PostgresStorage, error) {
+	if opts.StatementTimeout > 0 {
+		var err error
+		dsn, err = withDSNParam(dsn, "statement_timeout", strconv.FormatInt(opts.StatementTimeout.Milliseconds(), 10))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return nil, err
 	}
+	opts.applyPool(db)
+
+	if err := waitForDatabase(ctx, db, opts.ConnectTimeout); err != nil {
+		db.Close()
+		return nil, err
+	}
 
-	return &PostgresStorage{db: db}, nil
+	s := &PostgresStorage{db: db, q: db, cipher: cipher}
+	if opts.SkipMigrations {
+		return s, nil
+	}
+	if err := s.Migrate(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	return s, nil
 }
 
-func (s *PostgresStorage) SaveAuthState(state string, chatID int64) error {
-	_, err := s.db.Exec(`
-		INSERT INTO auth_state (state, chat_id)
-		VALUES ($1, $2)
-		ON CONFLICT (state) DO UPDATE SET chat_id = EXCLUDED.chat_id
-	`, state, chatID)
-	return err
+func (s *PostgresStorage) Close() error {
+	return s.db.Close()
 }
 
-func (s *PostgresStorage) GetChatIDbyAuthState(state string) (int64, error) {
-	var chatID int64
-	err := s.db.QueryRow(`
-		SELECT chat_id FROM auth_state WHERE state = $1
-	`, state).Scan(&chatID)
-	log.Printf("get chat id by state err: %v", err)
-	return chatID, err
+func (s *PostgresStorage) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	return s.inTx(ctx, nil, func(tx *PostgresStorage) error {
+		return fn(tx)
+	})
+}
+
+// inTx выполняет fn в транзакции. Если s уже получено из транзакции, fn выполняется в ней же
+func (s *PostgresStorage) inTx(ctx context.Context, opts *sql.TxOptions, fn func(tx *PostgresStorage) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	return runInTx(ctx, s.db, opts, func(tx *sql.Tx) error {
+		return fn(&PostgresStorage{db: s.db, q: tx, tx: tx, cipher: s.cipher})
+	})
+}
+
+// SaveAuthState сохраняет новое состояние и удаляет все выданные чату ранее
+func (s *PostgresStorage) SaveAuthState(ctx context.Context, authState AuthState) error {
+	return s.inTx(ctx, nil, func(tx *PostgresStorage) error {
+		_, err := tx.q.ExecContext(ctx, `
+			DELETE FROM auth_state WHERE chat_id = $1
+		`, authState.ChatID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.q.ExecContext(ctx, `
+			INSERT INTO auth_state (state, chat_id, code_verifier, created_at)
+			VALUES ($1, $2, $3, now())
+			ON CONFLICT (state) DO UPDATE
+			SET chat_id = EXCLUDED.chat_id,
+			    code_verifier = EXCLUDED.code_verifier,
+			    created_at = EXCLUDED.created_at
+		`, authState.State, authState.ChatID, authState.CodeVerifier)
+		return err
+	})
 }
 
-func (s *PostgresStorage) DeleteAuthState(state string) error {
-	_, err := s.db.Exec(`
+// ConsumeAuthState возвращает состояние и сразу удаляет его, так что оно используется только один раз
+func (s *PostgresStorage) ConsumeAuthState(ctx context.Context, state string) (AuthState, error) {
+	authState := AuthState{State: state}
+	err := s.q.QueryRowContext(ctx, `
+		DELETE FROM auth_state WHERE state = $1
+		RETURNING chat_id, code_verifier, created_at
+	`, state).Scan(&authState.ChatID, &authState.CodeVerifier, &authState.CreatedAt)
+	return authState, err
+}
+
+func (s *PostgresStorage) DeleteExpiredAuthStates(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.q.ExecContext(ctx, `
+		DELETE FROM auth_state WHERE created_at < $1
+	`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *PostgresStorage) DeleteAuthState(ctx context.Context, state string) error {
+	_, err := s.q.ExecContext(ctx, `
 		DELETE FROM auth_state WHERE state = $1
 	`, state)
 	return err
 }
 
-func (s *PostgresStorage) SaveToken(chatID int64, token oauth2.Token) error {
-	_, err := s.db.Exec(`
-		INSERT INTO token (chat_id, access_token, token_type, refresh_token, expiry)
-		VALUES ($1, $2, $3, $4, $5)
+func (s *PostgresStorage) DeleteAuthStates(ctx context.Context, chatID int64) error {
+	_, err := s.q.ExecContext(ctx, `
+		DELETE FROM auth_state WHERE chat_id = $1
+	`, chatID)
+	return err
+}
+
+func (s *PostgresStorage) SaveToken(ctx context.Context, chatID int64, token oauth2.Token) error {
+	encrypted, err := s.cipher.encryptToken(chatID, token.AccessToken, token.RefreshToken)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt token: %w", err)
+	}
+
+	_, err = s.q.ExecContext(ctx, `
+		INSERT INTO token (chat_id, access_token, token_type, refresh_token, expiry, key_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		ON CONFLICT (chat_id) DO UPDATE
 		SET access_token = EXCLUDED.access_token,
 		    token_type = EXCLUDED.token_type,
 		    refresh_token = EXCLUDED.refresh_token,
-		    expiry = EXCLUDED.expiry
-	`, chatID, token.AccessToken, token.TokenType, token.RefreshToken, token.Expiry)
+		    expiry = EXCLUDED.expiry,
+		    key_id = EXCLUDED.key_id
+	`, chatID, encrypted.AccessToken, token.TokenType, encrypted.RefreshToken, token.Expiry, encrypted.KeyID)
 	return err
 }
 
-func (s *PostgresStorage) GetToken(chatID int64) (oauth2.Token, error) {
+func (s *PostgresStorage) GetToken(ctx context.Context, chatID int64) (oauth2.Token, error) {
 	var token oauth2.Token
+	var encrypted encryptedToken
 
-	err := s.db.QueryRow(`
-		SELECT access_token, token_type, refresh_token, expiry
+	query := `
+		SELECT access_token, token_type, refresh_token, expiry, key_id
 		FROM token WHERE chat_id = $1
-	`, chatID).Scan(&token.AccessToken, &token.TokenType, &token.RefreshToken, &token.Expiry)
+	`
+	if s.tx != nil {
+		// строка блокируется до конца транзакции: параллельное обновление токена дождётся этого
+		query += ` FOR UPDATE`
+	}
+
+	err := s.q.QueryRowContext(ctx, query, chatID).Scan(&encrypted.AccessToken, &token.TokenType, &encrypted.RefreshToken, &token.Expiry, &encrypted.KeyID)
+
+	if err != nil {
+		return oauth2.Token{}, err
+	}
 
+	token.AccessToken, token.RefreshToken, err = s.cipher.decryptToken(chatID, encrypted)
 	if err != nil {
 		return oauth2.Token{}, err
 	}
@@ -85,69 +189,520 @@ func (s *PostgresStorage) GetToken(chatID int64) (oauth2.Token, error) {
 	return token, nil
 }
 
-func (s *PostgresStorage) DeleteToken(chatID int64) error {
-	_, err := s.db.Exec(`
-		DELETE FROM token WHERE chat_id = $1
-	`, chatID)
-	return err
+// ReencryptTokens перешифровывает активным ключом все токены, записанные другим ключом или без шифрования.
+// Возвращает количество обновлённых строк
+func (s *PostgresStorage) ReencryptTokens(ctx context.Context) (int, error) {
+	var updated int
+	err := s.inTx(ctx, nil, func(tx *PostgresStorage) error {
+		rows, err := tx.q.QueryContext(ctx, `
+			SELECT chat_id, access_token, refresh_token, key_id
+			FROM token WHERE key_id <> $1
+			FOR UPDATE
+		`, s.cipher.ActiveKeyID())
+		if err != nil {
+			return err
+		}
+
+		type row struct {
+			chatID int64
+			token  encryptedToken
+		}
+
+		var outdated []row
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.chatID, &r.token.AccessToken, &r.token.RefreshToken, &r.token.KeyID); err != nil {
+				rows.Close()
+				return err
+			}
+			outdated = append(outdated, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, r := range outdated {
+			accessToken, refreshToken, err := s.cipher.decryptToken(r.chatID, r.token)
+			if err != nil {
+				return fmt.Errorf("chat %d: %w", r.chatID, err)
+			}
+
+			encrypted, err := s.cipher.encryptToken(r.chatID, accessToken, refreshToken)
+			if err != nil {
+				return fmt.Errorf("chat %d: %w", r.chatID, err)
+			}
+
+			_, err = tx.q.ExecContext(ctx, `
+				UPDATE token SET access_token = $2, refresh_token = $3, key_id = $4
+				WHERE chat_id = $1
+			`, r.chatID, encrypted.AccessToken, encrypted.RefreshToken, encrypted.KeyID)
+			if err != nil {
+				return fmt.Errorf("chat %d: %w", r.chatID, err)
+			}
+		}
+
+		updated = len(outdated)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return updated, nil
 }
 
-func (s *PostgresStorage) SaveCity(chatID int64, city string) error {
-	var cityID int
-	err := s.db.QueryRow(`
-		SELECT id FROM city WHERE city_name = $1
-	`, city).Scan(&cityID)
+func (s *PostgresStorage) GetExpiringTokens(ctx context.Context, before, activeSince time.Time) ([]int64, error) {
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT token.chat_id FROM token
+		JOIN chat ON chat.chat_id = token.chat_id
+		WHERE token.expiry < $1 AND chat.last_active_at >= $2
+	`, before, activeSince)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return fmt.Errorf("Город %q не найден в базе", city)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chatIDs []int64
+	for rows.Next() {
+		var chatID int64
+		if err := rows.Scan(&chatID); err != nil {
+			return nil, err
 		}
-		return err
+		chatIDs = append(chatIDs, chatID)
 	}
 
-	_, err = s.db.Exec(`
-		UPDATE chat SET city_id = $1 WHERE chat_id = $2
-	`, cityID, chatID)
+	return chatIDs, rows.Err()
+}
+
+func (s *PostgresStorage) DeleteToken(ctx context.Context, chatID int64) error {
+	_, err := s.q.ExecContext(ctx, `
+		DELETE FROM token WHERE chat_id = $1
+	`, chatID)
 	return err
 }
 
-func (s *PostgresStorage) GetCity(chatID int64) (string, error) {
-	var city string
-	err := s.db.QueryRow(`
-		SELECT c.city_name
-		FROM chat ch
-		JOIN city c ON ch.city_id = c.id
+// SaveCity ищет город в справочнике и привязывает его к чату. Чат должен уже существовать
+func (s *PostgresStorage) SaveCity(ctx context.Context, chatID int64, city string) error {
+	return s.inTx(ctx, nil, func(tx *PostgresStorage) error {
+		found, err := tx.FindCity(ctx, city)
+		if errors.Is(err, ErrCityNotFound) {
+			return fmt.Errorf("city %q: %w", city, err)
+		}
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.q.ExecContext(ctx, `
+			UPDATE chat SET city_id = $1 WHERE chat_id = $2
+		`, found.ID, chatID)
+		if err != nil {
+			return err
+		}
+		return requireAffected(result, ErrChatNotFound)
+	})
+}
+
+func (s *PostgresStorage) GetCity(ctx context.Context, chatID int64) (City, error) {
+	return scanCity(s.q.QueryRowContext(ctx, selectCity+`
+		JOIN chat ch ON ch.city_id = c.id
 		WHERE ch.chat_id = $1
-	`, chatID).Scan(&city)
+		GROUP BY c.id
+	`, chatID))
+}
+
+// FindCity ищет город по названию или альтернативному написанию без учёта регистра
+func (s *PostgresStorage) FindCity(ctx context.Context, name string) (City, error) {
+	city, err := scanCity(s.q.QueryRowContext(ctx, selectCity+`
+		WHERE lower(c.city_name) = $1
+		   OR c.id = (SELECT city_id FROM city_alias WHERE alias = $1)
+		GROUP BY c.id
+	`, normalizeCityName(name)))
+	if err == sql.ErrNoRows {
+		return City{}, ErrCityNotFound
+	}
 	return city, err
 }
 
-func (s *PostgresStorage) DeleteCity(chatID int64) error {
-	_, err := s.db.Exec(`
+// AddCity добавляет город в справочник и возвращает его с присвоенным ID
+func (s *PostgresStorage) AddCity(ctx context.Context, city City) (City, error) {
+	err := s.inTx(ctx, nil, func(tx *PostgresStorage) error {
+		err := tx.q.QueryRowContext(ctx, `
+			INSERT INTO city (city_name, timepad_name, latitude, longitude, timezone, population)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id
+		`, city.Name, city.TimepadName, city.Latitude, city.Longitude, city.Timezone, city.Population).Scan(&city.ID)
+		if err != nil {
+			return err
+		}
+
+		city.Aliases = normalizeAliases(city.Aliases)
+		return saveCityAliases(ctx, tx.q, city.ID, city.Aliases)
+	})
+	if err != nil {
+		return City{}, err
+	}
+	return city, nil
+}
+
+// UpdateCity перезаписывает город с city.ID вместе со списком альтернативных написаний
+func (s *PostgresStorage) UpdateCity(ctx context.Context, city City) error {
+	return s.inTx(ctx, nil, func(tx *PostgresStorage) error {
+		result, err := tx.q.ExecContext(ctx, `
+			UPDATE city
+			SET city_name = $2, timepad_name = $3, latitude = $4, longitude = $5, timezone = $6, population = $7
+			WHERE id = $1
+		`, city.ID, city.Name, city.TimepadName, city.Latitude, city.Longitude, city.Timezone, city.Population)
+		if err != nil {
+			return err
+		}
+		if err := requireAffected(result, ErrCityNotFound); err != nil {
+			return err
+		}
+
+		if _, err := tx.q.ExecContext(ctx, `DELETE FROM city_alias WHERE city_id = $1`, city.ID); err != nil {
+			return err
+		}
+		return saveCityAliases(ctx, tx.q, city.ID, normalizeAliases(city.Aliases))
+	})
+}
+
+const selectCity = `
+	SELECT c.id, c.city_name, c.timepad_name, c.latitude, c.longitude, c.timezone, c.population,
+	       COALESCE(array_agg(a.alias ORDER BY a.alias) FILTER (WHERE a.alias IS NOT NULL), '{}')
+	FROM city c
+	LEFT JOIN city_alias a ON a.city_id = c.id
+`
+
+func scanCity(row *sql.Row) (City, error) {
+	var city City
+	err := row.Scan(&city.ID, &city.Name, &city.TimepadName, &city.Latitude, &city.Longitude,
+		&city.Timezone, &city.Population, pq.Array(&city.Aliases))
+	return city, err
+}
+
+func saveCityAliases(ctx context.Context, q querier, cityID int, aliases []string) error {
+	for _, alias := range aliases {
+		_, err := q.ExecContext(ctx, `
+			INSERT INTO city_alias (alias, city_id) VALUES ($1, $2)
+		`, alias, cityID)
+		if err != nil {
+			return fmt.Errorf("alias %q: %w", alias, err)
+		}
+	}
+	return nil
+}
+
+func (s *PostgresStorage) DeleteCity(ctx context.Context, chatID int64) error {
+	_, err := s.q.ExecContext(ctx, `
 		UPDATE chat SET city_id = NULL WHERE chat_id = $1
 	`, chatID)
 	return err
 }
 
-func (s *PostgresStorage) SaveChatState(chatID int64, state ChatState) error {
-	_, err := s.db.Exec(`
-		INSERT INTO chat (chat_id, chat_state)
+// SaveChatState переводит чат в состояние без данных
+func (s *PostgresStorage) SaveChatState(ctx context.Context, chatID int64, state ChatState) error {
+	return s.SaveConversation(ctx, chatID, Conversation{State: state})
+}
+
+// SaveConversation сохраняет состояние диалога, создавая запись чата, если её ещё нет
+func (s *PostgresStorage) SaveConversation(ctx context.Context, chatID int64, conv Conversation) error {
+	if conv.UpdatedAt.IsZero() {
+		conv.UpdatedAt = time.Now()
+	}
+
+	_, err := s.q.ExecContext(ctx, `
+		INSERT INTO chat (chat_id, chat_state, state_payload, state_updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (chat_id) DO UPDATE
+		SET chat_state = EXCLUDED.chat_state,
+		    state_payload = EXCLUDED.state_payload,
+		    state_updated_at = EXCLUDED.state_updated_at
+	`, chatID, conv.State, nullPayload(conv.Payload), conv.UpdatedAt)
+	return err
+}
+
+func (s *PostgresStorage) GetConversation(ctx context.Context, chatID int64) (Conversation, error) {
+	return scanConversation(s.q.QueryRowContext(ctx, `
+		SELECT chat_state, state_payload, state_updated_at FROM chat WHERE chat_id = $1
+	`, chatID))
+}
+
+func (s *PostgresStorage) DeleteChatState(ctx context.Context, chatID int64) error {
+	_, err := s.q.ExecContext(ctx, `
+		UPDATE chat SET chat_state = $2, state_payload = NULL, state_updated_at = $3 WHERE chat_id = $1
+	`, chatID, DefaultChatState, time.Now())
+	return err
+}
+
+func (s *PostgresStorage) SaveChatActivity(ctx context.Context, chatID int64, at time.Time) error {
+	_, err := s.q.ExecContext(ctx, `
+		UPDATE chat SET last_active_at = $2 WHERE chat_id = $1
+	`, chatID, at)
+	return err
+}
+
+func (s *PostgresStorage) SaveLanguage(ctx context.Context, chatID int64, lang string) error {
+	result, err := s.q.ExecContext(ctx, `
+		UPDATE chat SET language = $1 WHERE chat_id = $2
+	`, lang, chatID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result, ErrChatNotFound)
+}
+
+func (s *PostgresStorage) GetLanguage(ctx context.Context, chatID int64) (string, error) {
+	var lang sql.NullString
+	err := s.q.QueryRowContext(ctx, `
+		SELECT language FROM chat WHERE chat_id = $1
+	`, chatID).Scan(&lang)
+	if err == sql.ErrNoRows {
+		return "", ErrChatNotFound
+	}
+	return lang.String, err
+}
+
+// DeleteChat удаляет все данные чата одной транзакцией
+func (s *PostgresStorage) DeleteChat(ctx context.Context, chatID int64) error {
+	return s.inTx(ctx, nil, func(tx *PostgresStorage) error {
+		queries := []string{
+			`DELETE FROM auth_state WHERE chat_id = $1`,
+			`DELETE FROM reminder WHERE chat_id = $1`,
+			`DELETE FROM calendar_feed WHERE chat_id = $1`,
+			`DELETE FROM token WHERE chat_id = $1`,
+			`DELETE FROM chat WHERE chat_id = $1`,
+		}
+		for _, query := range queries {
+			if _, err := tx.q.ExecContext(ctx, query, chatID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *PostgresStorage) SaveCalendarToken(ctx context.Context, chatID int64, token string) error {
+	_, err := s.q.ExecContext(ctx, `
+		INSERT INTO calendar_feed (chat_id, token)
 		VALUES ($1, $2)
-		ON CONFLICT (chat_id) DO UPDATE SET chat_state = EXCLUDED.chat_state
-	`, chatID, state)
+		ON CONFLICT (chat_id) DO UPDATE SET token = EXCLUDED.token
+	`, chatID, token)
 	return err
 }
 
-func (s *PostgresStorage) GetChatState(chatID int64) (ChatState, error) {
-	var state ChatState
-	err := s.db.QueryRow(`
-		SELECT chat_state FROM chat WHERE chat_id = $1
-	`, chatID).Scan(&state)
-	return state, err
+func (s *PostgresStorage) GetCalendarToken(ctx context.Context, chatID int64) (string, error) {
+	var token string
+	err := s.q.QueryRowContext(ctx, `
+		SELECT token FROM calendar_feed WHERE chat_id = $1
+	`, chatID).Scan(&token)
+	return token, err
 }
 
-func (s *PostgresStorage) DeleteChatState(chatID int64) error {
-	_, err := s.db.Exec(`
-		UPDATE chat SET chat_state = 0 WHERE chat_id = $1
+func (s *PostgresStorage) GetChatIDbyCalendarToken(ctx context.Context, token string) (int64, error) {
+	var chatID int64
+	err := s.q.QueryRowContext(ctx, `
+		SELECT chat_id FROM calendar_feed WHERE token = $1
+	`, token).Scan(&chatID)
+	return chatID, err
+}
+
+func (s *PostgresStorage) DeleteCalendarToken(ctx context.Context, chatID int64) error {
+	_, err := s.q.ExecContext(ctx, `
+		DELETE FROM calendar_feed WHERE chat_id = $1
 	`, chatID)
 	return err
 }
+
+func (s *PostgresStorage) SaveReminder(ctx context.Context, reminder Reminder) error {
+	_, err := s.q.ExecContext(ctx, `
+		INSERT INTO reminder (chat_id, concert_id, concert_name, concert_url, starts_at, utc_offset, remind_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (chat_id, concert_id, remind_at) DO NOTHING
+	`, reminder.ChatID, reminder.ConcertID, reminder.ConcertName, reminder.ConcertURL,
+		reminder.StartsAt, utcOffset(reminder.StartsAt), reminder.RemindAt)
+	return err
+}
+
+// GetReminders возвращает ещё не отправленные напоминания чата
+func (s *PostgresStorage) GetReminders(ctx context.Context, chatID int64) ([]Reminder, error) {
+	return queryReminders(ctx, s.q, `
+		SELECT id, chat_id, concert_id, concert_name, concert_url, starts_at, utc_offset, remind_at, sent_at, attempts, retry_at
+		FROM reminder WHERE chat_id = $1 AND sent_at IS NULL
+		ORDER BY remind_at
+	`, chatID)
+}
+
+func (s *PostgresStorage) GetDueReminders(ctx context.Context, now time.Time) ([]Reminder, error) {
+	return queryReminders(ctx, s.q, `
+		SELECT id, chat_id, concert_id, concert_name, concert_url, starts_at, utc_offset, remind_at, sent_at, attempts, retry_at
+		FROM reminder WHERE COALESCE(retry_at, remind_at) <= $1 AND sent_at IS NULL
+		ORDER BY COALESCE(retry_at, remind_at)
+	`, now)
+}
+
+// querier общий интерфейс *sql.DB и *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// requireAffected возвращает notFound, если запрос не изменил ни одной строки
+func requireAffected(result sql.Result, notFound error) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return notFound
+	}
+	return nil
+}
+
+func runInTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func queryReminders(ctx context.Context, q querier, query string, args ...any) ([]Reminder, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reminders := []Reminder{}
+	for rows.Next() {
+		var r Reminder
+		var offset int
+		err := rows.Scan(&r.ID, &r.ChatID, &r.ConcertID, &r.ConcertName, &r.ConcertURL, &r.StartsAt, &offset, &r.RemindAt, &r.SentAt, &r.Attempts, &r.RetryAt)
+		if err != nil {
+			return nil, err
+		}
+		// postgres не хранит часовой пояс, восстанавливаем местное время концерта
+		r.StartsAt = r.StartsAt.In(time.FixedZone("", offset))
+		reminders = append(reminders, r)
+	}
+
+	return reminders, rows.Err()
+}
+
+func (s *PostgresStorage) RetryReminder(ctx context.Context, id int64, retryAt time.Time) error {
+	_, err := s.q.ExecContext(ctx, `
+		UPDATE reminder SET retry_at = $2, attempts = attempts + 1 WHERE id = $1
+	`, id, retryAt)
+	return err
+}
+
+func (s *PostgresStorage) DeleteReminder(ctx context.Context, id int64) error {
+	_, err := s.q.ExecContext(ctx, `
+		DELETE FROM reminder WHERE id = $1
+	`, id)
+	return err
+}
+
+// MarkReminderSent помечает напоминание отправленным, оно остаётся в истории уведомлений
+func (s *PostgresStorage) MarkReminderSent(ctx context.Context, id int64, sentAt time.Time) error {
+	_, err := s.q.ExecContext(ctx, `
+		UPDATE reminder SET sent_at = $2 WHERE id = $1
+	`, id, sentAt)
+	return err
+}
+
+func (s *PostgresStorage) DeleteSentReminders(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.q.ExecContext(ctx, `
+		DELETE FROM reminder WHERE sent_at < $1
+	`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *PostgresStorage) DeleteConcertReminders(ctx context.Context, chatID int64, concertID int) error {
+	_, err := s.q.ExecContext(ctx, `
+		DELETE FROM reminder WHERE chat_id = $1 AND concert_id = $2 AND sent_at IS NULL
+	`, chatID, concertID)
+	return err
+}
+
+// ExportChat собирает все данные чата из всех таблиц в одной транзакции
+func (s *PostgresStorage) ExportChat(ctx context.Context, chatID int64) (ChatExport, error) {
+	export := ChatExport{ChatID: chatID, Reminders: []Reminder{}, Notifications: []Reminder{}}
+
+	err := s.inTx(ctx, &sql.TxOptions{ReadOnly: true, Isolation: sql.LevelRepeatableRead}, func(tx *PostgresStorage) error {
+		var city, language sql.NullString
+		err := tx.q.QueryRowContext(ctx, `
+			SELECT ch.chat_state, ch.language, c.city_name
+			FROM chat ch
+			LEFT JOIN city c ON ch.city_id = c.id
+			WHERE ch.chat_id = $1
+		`, chatID).Scan(&export.ChatState, &language, &city)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		export.City = city.String
+		export.Language = language.String
+
+		var account LinkedAccount
+		err = tx.q.QueryRowContext(ctx, `
+			SELECT token_type, expiry FROM token WHERE chat_id = $1
+		`, chatID).Scan(&account.TokenType, &account.TokenExpiry)
+		switch {
+		case err == nil:
+			export.Spotify = &account
+		case err != sql.ErrNoRows:
+			return err
+		}
+
+		err = tx.q.QueryRowContext(ctx, `
+			SELECT EXISTS (SELECT 1 FROM calendar_feed WHERE chat_id = $1)
+		`, chatID).Scan(&export.CalendarFeed)
+		if err != nil {
+			return err
+		}
+
+		err = tx.q.QueryRowContext(ctx, `
+			SELECT count(*) FROM auth_state WHERE chat_id = $1
+		`, chatID).Scan(&export.PendingAuth)
+		if err != nil {
+			return err
+		}
+
+		reminders, err := queryReminders(ctx, tx.q, `
+			SELECT id, chat_id, concert_id, concert_name, concert_url, starts_at, utc_offset, remind_at, sent_at, attempts, retry_at
+			FROM reminder WHERE chat_id = $1
+			ORDER BY remind_at
+		`, chatID)
+		if err != nil {
+			return err
+		}
+		for _, r := range reminders {
+			if r.SentAt != nil {
+				export.Notifications = append(export.Notifications, r)
+			} else {
+				export.Reminders = append(export.Reminders, r)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return ChatExport{}, err
+	}
+	return export, nil
+}
+
+func utcOffset(t time.Time) int {
+	_, offset := t.Zone()
+	return offset
+}
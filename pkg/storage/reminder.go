@@ -0,0 +1,25 @@
+package storage
+
+import "time"
+
+type Reminder struct {
+	ID          int64      `json:"-"`
+	ChatID      int64      `json:"-"`
+	ConcertID   int        `json:"concert_id"`
+	ConcertName string     `json:"concert_name"`
+	ConcertURL  string     `json:"concert_url"`
+	StartsAt    time.Time  `json:"starts_at"`
+	RemindAt    time.Time  `json:"remind_at"`
+	SentAt      *time.Time `json:"sent_at,omitempty"`
+	// Attempts сколько раз отправка не удалась, RetryAt когда будет следующая попытка
+	Attempts int        `json:"-"`
+	RetryAt  *time.Time `json:"-"`
+}
+
+// DueAt когда напоминание нужно отправить: в RemindAt или при повторе в RetryAt
+func (r Reminder) DueAt() time.Time {
+	if r.RetryAt != nil {
+		return *r.RetryAt
+	}
+	return r.RemindAt
+}
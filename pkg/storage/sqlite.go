@@ -0,0 +1,697 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/shakareem/gigoseek/pkg/config"
+	"golang.org/x/oauth2"
+	"modernc.org/sqlite"
+)
+
+const defaultSQLiteBusyTimeout = 5 * time.Second
+
+// errSQLitePathRequired путь к файлу базы не задан в конфиге
+var errSQLitePathRequired = errors.New("database.path is required for sqlite")
+
+// SQLiteStorage хранит данные в одном файле, без отдельного сервера базы данных.
+// Схема и поведение те же, что у PostgresStorage; время хранится в UTC, чтобы строки сравнивались как моменты времени
+type SQLiteStorage struct {
+	db *sql.DB
+	// q соединение для запросов: сама база или транзакция, если хранилище получено из WithTx
+	q      querier
+	tx     *sql.Tx
+	cipher *TokenCipher
+}
+
+func init() {
+	// встроенная lower в sqlite понимает только ASCII, а города называются по-русски
+	sqlite.MustRegisterDeterministicScalarFunction("unicode_lower", 1,
+		func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			s, ok := args[0].(string)
+			if !ok {
+				return args[0], nil
+			}
+			return strings.ToLower(s), nil
+		})
+}
+
+// NewSQLiteStorage открывает базу из конфига. migrate=false оставляет схему как есть
+func NewSQLiteStorage(ctx context.Context, migrate bool) (*SQLiteStorage, error) {
+	cfg := config.Get().Database
+
+	cipher, err := NewTokenCipher(config.Get().TokenEncryptionKeys)
+	if err != nil {
+		return nil, fmt.Errorf("token encryption: %w", err)
+	}
+
+	if cfg.Path == "" {
+		return nil, errSQLitePathRequired
+	}
+
+	return OpenSQLiteStorage(ctx, cfg.Path, cipher, optionsFromConfig(cfg, migrate))
+}
+
+// OpenSQLiteStorage открывает или создаёт файл базы и применяет миграции, если не задан opts.SkipMigrations
+func OpenSQLiteStorage(ctx context.Context, path string, cipher *TokenCipher, opts Options) (*SQLiteStorage, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	// запросы в sqlite ждут не сервер, а блокировку файла, поэтому таймаут запроса становится busy_timeout
+	busyTimeout := defaultSQLiteBusyTimeout
+	if opts.StatementTimeout > 0 {
+		busyTimeout = opts.StatementTimeout
+	}
+
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeout.Milliseconds()))
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Set("_time_format", "sqlite")
+	// транзакция сразу берёт блокировку на запись, поэтому транзакции из WithTx выполняются по очереди
+	// и не получают SQLITE_BUSY при попытке записи после чтения
+	params.Set("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	opts.applyPool(db)
+
+	if err := waitForDatabase(ctx, db, opts.ConnectTimeout); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	s := &SQLiteStorage{db: db, q: db, cipher: cipher}
+	if opts.SkipMigrations {
+		return s, nil
+	}
+	if err := s.Migrate(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	return s, nil
+}
+
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteStorage) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	return s.inTx(ctx, nil, func(tx *SQLiteStorage) error {
+		return fn(tx)
+	})
+}
+
+// inTx выполняет fn в транзакции. Если s уже получено из транзакции, fn выполняется в ней же
+func (s *SQLiteStorage) inTx(ctx context.Context, opts *sql.TxOptions, fn func(tx *SQLiteStorage) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	return runInTx(ctx, s.db, opts, func(tx *sql.Tx) error {
+		return fn(&SQLiteStorage{db: s.db, q: tx, tx: tx, cipher: s.cipher})
+	})
+}
+
+// Migrate применяет миграции sqlite. Advisory lock не нужен: файл базы принадлежит одному процессу
+func (s *SQLiteStorage) Migrate(ctx context.Context) error {
+	migrations, err := loadMigrations(sqliteMigrationsDir)
+	if err != nil {
+		return err
+	}
+
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return applyMigrations(ctx, conn, migrations)
+}
+
+func (s *SQLiteStorage) Rollback(ctx context.Context, steps int) error {
+	migrations, err := loadMigrations(sqliteMigrationsDir)
+	if err != nil {
+		return err
+	}
+
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return rollbackMigrations(ctx, conn, migrations, steps)
+}
+
+func (s *SQLiteStorage) SaveAuthState(ctx context.Context, authState AuthState) error {
+	return s.inTx(ctx, nil, func(tx *SQLiteStorage) error {
+		_, err := tx.q.ExecContext(ctx, `
+			DELETE FROM auth_state WHERE chat_id = $1
+		`, authState.ChatID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.q.ExecContext(ctx, `
+			INSERT INTO auth_state (state, chat_id, code_verifier, created_at)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (state) DO UPDATE
+			SET chat_id = EXCLUDED.chat_id,
+			    code_verifier = EXCLUDED.code_verifier,
+			    created_at = EXCLUDED.created_at
+		`, authState.State, authState.ChatID, authState.CodeVerifier, time.Now().UTC())
+		return err
+	})
+}
+
+func (s *SQLiteStorage) ConsumeAuthState(ctx context.Context, state string) (AuthState, error) {
+	authState := AuthState{State: state}
+	err := s.q.QueryRowContext(ctx, `
+		DELETE FROM auth_state WHERE state = $1
+		RETURNING chat_id, code_verifier, created_at
+	`, state).Scan(&authState.ChatID, &authState.CodeVerifier, &authState.CreatedAt)
+	return authState, err
+}
+
+func (s *SQLiteStorage) DeleteExpiredAuthStates(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.q.ExecContext(ctx, `
+		DELETE FROM auth_state WHERE created_at < $1
+	`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *SQLiteStorage) DeleteAuthState(ctx context.Context, state string) error {
+	_, err := s.q.ExecContext(ctx, `
+		DELETE FROM auth_state WHERE state = $1
+	`, state)
+	return err
+}
+
+func (s *SQLiteStorage) DeleteAuthStates(ctx context.Context, chatID int64) error {
+	_, err := s.q.ExecContext(ctx, `
+		DELETE FROM auth_state WHERE chat_id = $1
+	`, chatID)
+	return err
+}
+
+func (s *SQLiteStorage) SaveToken(ctx context.Context, chatID int64, token oauth2.Token) error {
+	encrypted, err := s.cipher.encryptToken(chatID, token.AccessToken, token.RefreshToken)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt token: %w", err)
+	}
+
+	_, err = s.q.ExecContext(ctx, `
+		INSERT INTO token (chat_id, access_token, token_type, refresh_token, expiry, key_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (chat_id) DO UPDATE
+		SET access_token = EXCLUDED.access_token,
+		    token_type = EXCLUDED.token_type,
+		    refresh_token = EXCLUDED.refresh_token,
+		    expiry = EXCLUDED.expiry,
+		    key_id = EXCLUDED.key_id
+	`, chatID, encrypted.AccessToken, token.TokenType, encrypted.RefreshToken, token.Expiry.UTC(), encrypted.KeyID)
+	return err
+}
+
+func (s *SQLiteStorage) GetToken(ctx context.Context, chatID int64) (oauth2.Token, error) {
+	var token oauth2.Token
+	var encrypted encryptedToken
+
+	err := s.q.QueryRowContext(ctx, `
+		SELECT access_token, token_type, refresh_token, expiry, key_id
+		FROM token WHERE chat_id = $1
+	`, chatID).Scan(&encrypted.AccessToken, &token.TokenType, &encrypted.RefreshToken, &token.Expiry, &encrypted.KeyID)
+
+	if err != nil {
+		return oauth2.Token{}, err
+	}
+
+	token.AccessToken, token.RefreshToken, err = s.cipher.decryptToken(chatID, encrypted)
+	if err != nil {
+		return oauth2.Token{}, err
+	}
+
+	return token, nil
+}
+
+// ReencryptTokens перешифровывает активным ключом все токены, записанные другим ключом или без шифрования
+func (s *SQLiteStorage) ReencryptTokens(ctx context.Context) (int, error) {
+	var updated int
+	err := s.inTx(ctx, nil, func(tx *SQLiteStorage) error {
+		rows, err := tx.q.QueryContext(ctx, `
+			SELECT chat_id, access_token, refresh_token, key_id
+			FROM token WHERE key_id <> $1
+		`, s.cipher.ActiveKeyID())
+		if err != nil {
+			return err
+		}
+
+		type row struct {
+			chatID int64
+			token  encryptedToken
+		}
+
+		var outdated []row
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.chatID, &r.token.AccessToken, &r.token.RefreshToken, &r.token.KeyID); err != nil {
+				rows.Close()
+				return err
+			}
+			outdated = append(outdated, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, r := range outdated {
+			accessToken, refreshToken, err := s.cipher.decryptToken(r.chatID, r.token)
+			if err != nil {
+				return fmt.Errorf("chat %d: %w", r.chatID, err)
+			}
+
+			encrypted, err := s.cipher.encryptToken(r.chatID, accessToken, refreshToken)
+			if err != nil {
+				return fmt.Errorf("chat %d: %w", r.chatID, err)
+			}
+
+			_, err = tx.q.ExecContext(ctx, `
+				UPDATE token SET access_token = $2, refresh_token = $3, key_id = $4
+				WHERE chat_id = $1
+			`, r.chatID, encrypted.AccessToken, encrypted.RefreshToken, encrypted.KeyID)
+			if err != nil {
+				return fmt.Errorf("chat %d: %w", r.chatID, err)
+			}
+		}
+
+		updated = len(outdated)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return updated, nil
+}
+
+func (s *SQLiteStorage) GetExpiringTokens(ctx context.Context, before, activeSince time.Time) ([]int64, error) {
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT token.chat_id FROM token
+		JOIN chat ON chat.chat_id = token.chat_id
+		WHERE token.expiry < $1 AND chat.last_active_at >= $2
+	`, before.UTC(), activeSince.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chatIDs []int64
+	for rows.Next() {
+		var chatID int64
+		if err := rows.Scan(&chatID); err != nil {
+			return nil, err
+		}
+		chatIDs = append(chatIDs, chatID)
+	}
+
+	return chatIDs, rows.Err()
+}
+
+func (s *SQLiteStorage) DeleteToken(ctx context.Context, chatID int64) error {
+	_, err := s.q.ExecContext(ctx, `
+		DELETE FROM token WHERE chat_id = $1
+	`, chatID)
+	return err
+}
+
+// SaveCity ищет город в справочнике и привязывает его к чату. Чат должен уже существовать
+func (s *SQLiteStorage) SaveCity(ctx context.Context, chatID int64, city string) error {
+	return s.inTx(ctx, nil, func(tx *SQLiteStorage) error {
+		found, err := tx.FindCity(ctx, city)
+		if errors.Is(err, ErrCityNotFound) {
+			return fmt.Errorf("city %q: %w", city, err)
+		}
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.q.ExecContext(ctx, `
+			UPDATE chat SET city_id = $1 WHERE chat_id = $2
+		`, found.ID, chatID)
+		if err != nil {
+			return err
+		}
+		return requireAffected(result, ErrChatNotFound)
+	})
+}
+
+func (s *SQLiteStorage) GetCity(ctx context.Context, chatID int64) (City, error) {
+	return scanSQLiteCity(s.q.QueryRowContext(ctx, selectSQLiteCity+`
+		JOIN chat ch ON ch.city_id = c.id
+		WHERE ch.chat_id = $1
+		GROUP BY c.id
+	`, chatID))
+}
+
+func (s *SQLiteStorage) FindCity(ctx context.Context, name string) (City, error) {
+	city, err := scanSQLiteCity(s.q.QueryRowContext(ctx, selectSQLiteCity+`
+		WHERE unicode_lower(c.city_name) = $1
+		   OR c.id = (SELECT city_id FROM city_alias WHERE alias = $1)
+		GROUP BY c.id
+	`, normalizeCityName(name)))
+	if err == sql.ErrNoRows {
+		return City{}, ErrCityNotFound
+	}
+	return city, err
+}
+
+func (s *SQLiteStorage) AddCity(ctx context.Context, city City) (City, error) {
+	err := s.inTx(ctx, nil, func(tx *SQLiteStorage) error {
+		err := tx.q.QueryRowContext(ctx, `
+			INSERT INTO city (city_name, timepad_name, latitude, longitude, timezone, population)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id
+		`, city.Name, city.TimepadName, city.Latitude, city.Longitude, city.Timezone, city.Population).Scan(&city.ID)
+		if err != nil {
+			return err
+		}
+
+		city.Aliases = normalizeAliases(city.Aliases)
+		return saveCityAliases(ctx, tx.q, city.ID, city.Aliases)
+	})
+	if err != nil {
+		return City{}, err
+	}
+	return city, nil
+}
+
+func (s *SQLiteStorage) UpdateCity(ctx context.Context, city City) error {
+	return s.inTx(ctx, nil, func(tx *SQLiteStorage) error {
+		result, err := tx.q.ExecContext(ctx, `
+			UPDATE city
+			SET city_name = $2, timepad_name = $3, latitude = $4, longitude = $5, timezone = $6, population = $7
+			WHERE id = $1
+		`, city.ID, city.Name, city.TimepadName, city.Latitude, city.Longitude, city.Timezone, city.Population)
+		if err != nil {
+			return err
+		}
+		if err := requireAffected(result, ErrCityNotFound); err != nil {
+			return err
+		}
+
+		if _, err := tx.q.ExecContext(ctx, `DELETE FROM city_alias WHERE city_id = $1`, city.ID); err != nil {
+			return err
+		}
+		return saveCityAliases(ctx, tx.q, city.ID, normalizeAliases(city.Aliases))
+	})
+}
+
+// selectSQLiteCity то же, что selectCity, но в sqlite нет массивов, поэтому написания склеиваются через перевод строки
+const selectSQLiteCity = `
+	SELECT c.id, c.city_name, c.timepad_name, c.latitude, c.longitude, c.timezone, c.population,
+	       COALESCE((SELECT group_concat(alias, char(10)) FROM (
+	           SELECT alias FROM city_alias WHERE city_id = c.id ORDER BY alias
+	       )), '')
+	FROM city c
+`
+
+func scanSQLiteCity(row *sql.Row) (City, error) {
+	var city City
+	var aliases string
+	err := row.Scan(&city.ID, &city.Name, &city.TimepadName, &city.Latitude, &city.Longitude,
+		&city.Timezone, &city.Population, &aliases)
+	if aliases != "" {
+		city.Aliases = strings.Split(aliases, "\n")
+	}
+	return city, err
+}
+
+func (s *SQLiteStorage) DeleteCity(ctx context.Context, chatID int64) error {
+	_, err := s.q.ExecContext(ctx, `
+		UPDATE chat SET city_id = NULL WHERE chat_id = $1
+	`, chatID)
+	return err
+}
+
+// SaveChatState переводит чат в состояние без данных
+func (s *SQLiteStorage) SaveChatState(ctx context.Context, chatID int64, state ChatState) error {
+	return s.SaveConversation(ctx, chatID, Conversation{State: state})
+}
+
+// SaveConversation сохраняет состояние диалога, создавая запись чата, если её ещё нет
+func (s *SQLiteStorage) SaveConversation(ctx context.Context, chatID int64, conv Conversation) error {
+	if conv.UpdatedAt.IsZero() {
+		conv.UpdatedAt = time.Now()
+	}
+
+	_, err := s.q.ExecContext(ctx, `
+		INSERT INTO chat (chat_id, chat_state, state_payload, state_updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (chat_id) DO UPDATE
+		SET chat_state = EXCLUDED.chat_state,
+		    state_payload = EXCLUDED.state_payload,
+		    state_updated_at = EXCLUDED.state_updated_at
+	`, chatID, conv.State, nullPayload(conv.Payload), conv.UpdatedAt.UTC())
+	return err
+}
+
+func (s *SQLiteStorage) GetConversation(ctx context.Context, chatID int64) (Conversation, error) {
+	return scanConversation(s.q.QueryRowContext(ctx, `
+		SELECT chat_state, state_payload, state_updated_at FROM chat WHERE chat_id = $1
+	`, chatID))
+}
+
+func (s *SQLiteStorage) DeleteChatState(ctx context.Context, chatID int64) error {
+	_, err := s.q.ExecContext(ctx, `
+		UPDATE chat SET chat_state = $2, state_payload = NULL, state_updated_at = $3 WHERE chat_id = $1
+	`, chatID, DefaultChatState, time.Now().UTC())
+	return err
+}
+
+func (s *SQLiteStorage) SaveChatActivity(ctx context.Context, chatID int64, at time.Time) error {
+	_, err := s.q.ExecContext(ctx, `
+		UPDATE chat SET last_active_at = $2 WHERE chat_id = $1
+	`, chatID, at.UTC())
+	return err
+}
+
+func (s *SQLiteStorage) SaveLanguage(ctx context.Context, chatID int64, lang string) error {
+	result, err := s.q.ExecContext(ctx, `
+		UPDATE chat SET language = ? WHERE chat_id = ?
+	`, lang, chatID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result, ErrChatNotFound)
+}
+
+func (s *SQLiteStorage) GetLanguage(ctx context.Context, chatID int64) (string, error) {
+	var lang sql.NullString
+	err := s.q.QueryRowContext(ctx, `
+		SELECT language FROM chat WHERE chat_id = ?
+	`, chatID).Scan(&lang)
+	if err == sql.ErrNoRows {
+		return "", ErrChatNotFound
+	}
+	return lang.String, err
+}
+
+func (s *SQLiteStorage) DeleteChat(ctx context.Context, chatID int64) error {
+	return s.inTx(ctx, nil, func(tx *SQLiteStorage) error {
+		queries := []string{
+			`DELETE FROM auth_state WHERE chat_id = $1`,
+			`DELETE FROM reminder WHERE chat_id = $1`,
+			`DELETE FROM calendar_feed WHERE chat_id = $1`,
+			`DELETE FROM token WHERE chat_id = $1`,
+			`DELETE FROM chat WHERE chat_id = $1`,
+		}
+		for _, query := range queries {
+			if _, err := tx.q.ExecContext(ctx, query, chatID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *SQLiteStorage) ExportChat(ctx context.Context, chatID int64) (ChatExport, error) {
+	export := ChatExport{ChatID: chatID, Reminders: []Reminder{}, Notifications: []Reminder{}}
+
+	// в sqlite читающая транзакция и так видит один снимок базы
+	err := s.inTx(ctx, &sql.TxOptions{ReadOnly: true}, func(tx *SQLiteStorage) error {
+		var city, language sql.NullString
+		err := tx.q.QueryRowContext(ctx, `
+			SELECT ch.chat_state, ch.language, c.city_name
+			FROM chat ch
+			LEFT JOIN city c ON ch.city_id = c.id
+			WHERE ch.chat_id = $1
+		`, chatID).Scan(&export.ChatState, &language, &city)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		export.City = city.String
+		export.Language = language.String
+
+		var account LinkedAccount
+		err = tx.q.QueryRowContext(ctx, `
+			SELECT token_type, expiry FROM token WHERE chat_id = $1
+		`, chatID).Scan(&account.TokenType, &account.TokenExpiry)
+		switch {
+		case err == nil:
+			export.Spotify = &account
+		case err != sql.ErrNoRows:
+			return err
+		}
+
+		err = tx.q.QueryRowContext(ctx, `
+			SELECT EXISTS (SELECT 1 FROM calendar_feed WHERE chat_id = $1)
+		`, chatID).Scan(&export.CalendarFeed)
+		if err != nil {
+			return err
+		}
+
+		err = tx.q.QueryRowContext(ctx, `
+			SELECT count(*) FROM auth_state WHERE chat_id = $1
+		`, chatID).Scan(&export.PendingAuth)
+		if err != nil {
+			return err
+		}
+
+		reminders, err := queryReminders(ctx, tx.q, `
+			SELECT id, chat_id, concert_id, concert_name, concert_url, starts_at, utc_offset, remind_at, sent_at, attempts, retry_at
+			FROM reminder WHERE chat_id = $1
+			ORDER BY remind_at
+		`, chatID)
+		if err != nil {
+			return err
+		}
+		for _, r := range reminders {
+			if r.SentAt != nil {
+				export.Notifications = append(export.Notifications, r)
+			} else {
+				export.Reminders = append(export.Reminders, r)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return ChatExport{}, err
+	}
+	return export, nil
+}
+
+func (s *SQLiteStorage) SaveCalendarToken(ctx context.Context, chatID int64, token string) error {
+	_, err := s.q.ExecContext(ctx, `
+		INSERT INTO calendar_feed (chat_id, token)
+		VALUES ($1, $2)
+		ON CONFLICT (chat_id) DO UPDATE SET token = EXCLUDED.token
+	`, chatID, token)
+	return err
+}
+
+func (s *SQLiteStorage) GetCalendarToken(ctx context.Context, chatID int64) (string, error) {
+	var token string
+	err := s.q.QueryRowContext(ctx, `
+		SELECT token FROM calendar_feed WHERE chat_id = $1
+	`, chatID).Scan(&token)
+	return token, err
+}
+
+func (s *SQLiteStorage) GetChatIDbyCalendarToken(ctx context.Context, token string) (int64, error) {
+	var chatID int64
+	err := s.q.QueryRowContext(ctx, `
+		SELECT chat_id FROM calendar_feed WHERE token = $1
+	`, token).Scan(&chatID)
+	return chatID, err
+}
+
+func (s *SQLiteStorage) DeleteCalendarToken(ctx context.Context, chatID int64) error {
+	_, err := s.q.ExecContext(ctx, `
+		DELETE FROM calendar_feed WHERE chat_id = $1
+	`, chatID)
+	return err
+}
+
+func (s *SQLiteStorage) SaveReminder(ctx context.Context, reminder Reminder) error {
+	_, err := s.q.ExecContext(ctx, `
+		INSERT INTO reminder (chat_id, concert_id, concert_name, concert_url, starts_at, utc_offset, remind_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (chat_id, concert_id, remind_at) DO NOTHING
+	`, reminder.ChatID, reminder.ConcertID, reminder.ConcertName, reminder.ConcertURL,
+		reminder.StartsAt.UTC(), utcOffset(reminder.StartsAt), reminder.RemindAt.UTC())
+	return err
+}
+
+func (s *SQLiteStorage) GetReminders(ctx context.Context, chatID int64) ([]Reminder, error) {
+	return queryReminders(ctx, s.q, `
+		SELECT id, chat_id, concert_id, concert_name, concert_url, starts_at, utc_offset, remind_at, sent_at, attempts, retry_at
+		FROM reminder WHERE chat_id = $1 AND sent_at IS NULL
+		ORDER BY remind_at
+	`, chatID)
+}
+
+func (s *SQLiteStorage) GetDueReminders(ctx context.Context, now time.Time) ([]Reminder, error) {
+	return queryReminders(ctx, s.q, `
+		SELECT id, chat_id, concert_id, concert_name, concert_url, starts_at, utc_offset, remind_at, sent_at, attempts, retry_at
+		FROM reminder WHERE COALESCE(retry_at, remind_at) <= $1 AND sent_at IS NULL
+		ORDER BY COALESCE(retry_at, remind_at)
+	`, now.UTC())
+}
+
+func (s *SQLiteStorage) MarkReminderSent(ctx context.Context, id int64, sentAt time.Time) error {
+	_, err := s.q.ExecContext(ctx, `
+		UPDATE reminder SET sent_at = $2 WHERE id = $1
+	`, id, sentAt.UTC())
+	return err
+}
+
+func (s *SQLiteStorage) RetryReminder(ctx context.Context, id int64, retryAt time.Time) error {
+	_, err := s.q.ExecContext(ctx, `
+		UPDATE reminder SET retry_at = $2, attempts = attempts + 1 WHERE id = $1
+	`, id, retryAt.UTC())
+	return err
+}
+
+func (s *SQLiteStorage) DeleteReminder(ctx context.Context, id int64) error {
+	_, err := s.q.ExecContext(ctx, `
+		DELETE FROM reminder WHERE id = $1
+	`, id)
+	return err
+}
+
+func (s *SQLiteStorage) DeleteSentReminders(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.q.ExecContext(ctx, `
+		DELETE FROM reminder WHERE sent_at < $1
+	`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *SQLiteStorage) DeleteConcertReminders(ctx context.Context, chatID int64, concertID int) error {
+	_, err := s.q.ExecContext(ctx, `
+		DELETE FROM reminder WHERE chat_id = $1 AND concert_id = $2 AND sent_at IS NULL
+	`, chatID, concertID)
+	return err
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrChatNotFound чат ещё не заходил в бота: записи о нём нет
+var ErrChatNotFound = errors.New("chat not found")
+
+// Storage хранилище бота. Реализации: PostgresStorage, SQLiteStorage и InMemoryStorage
+type Storage interface {
+	// WithTx выполняет fn в одной транзакции: если fn вернула ошибку, ни одно изменение не сохраняется.
+	// Внутри fn нужно обращаться только к переданному tx, вложенный WithTx присоединяется к внешней транзакции
+	WithTx(ctx context.Context, fn func(tx Storage) error) error
+
+	SaveAuthState(ctx context.Context, authState AuthState) error
+	ConsumeAuthState(ctx context.Context, state string) (AuthState, error)
+	DeleteAuthState(ctx context.Context, state string) error
+	// DeleteAuthStates удаляет все ещё не использованные ссылки для авторизации чата
+	DeleteAuthStates(ctx context.Context, chatID int64) error
+	DeleteExpiredAuthStates(ctx context.Context, before time.Time) (int64, error)
+
+	SaveToken(ctx context.Context, chatID int64, token oauth2.Token) error
+	// GetToken внутри транзакции блокирует токен до её конца, чтобы его не обновили одновременно
+	GetToken(ctx context.Context, chatID int64) (oauth2.Token, error)
+	DeleteToken(ctx context.Context, chatID int64) error
+	// GetExpiringTokens возвращает чаты, токены которых истекают раньше before, из тех, что писали боту после activeSince
+	GetExpiringTokens(ctx context.Context, before, activeSince time.Time) ([]int64, error)
+
+	SaveCity(ctx context.Context, chatID int64, city string) error
+	GetCity(ctx context.Context, chatID int64) (City, error)
+	FindCity(ctx context.Context, name string) (City, error)
+	AddCity(ctx context.Context, city City) (City, error)
+	UpdateCity(ctx context.Context, city City) error
+	DeleteCity(ctx context.Context, chatID int64) error
+
+	SaveChatState(ctx context.Context, chatID int64, state ChatState) error
+	SaveConversation(ctx context.Context, chatID int64, conv Conversation) error
+	// GetConversation возвращает ErrChatNotFound, если чата ещё нет
+	GetConversation(ctx context.Context, chatID int64) (Conversation, error)
+	// DeleteChatState возвращает чат в DefaultChatState
+	DeleteChatState(ctx context.Context, chatID int64) error
+
+	// SaveChatActivity запоминает, когда чат последний раз писал боту. Если чата ещё нет, ничего не делает
+	SaveChatActivity(ctx context.Context, chatID int64, at time.Time) error
+
+	// SaveLanguage возвращает ErrChatNotFound, если чата ещё нет
+	SaveLanguage(ctx context.Context, chatID int64, lang string) error
+	// GetLanguage возвращает пустую строку, если язык ещё не выбран, и ErrChatNotFound, если чата нет
+	GetLanguage(ctx context.Context, chatID int64) (string, error)
+
+	DeleteChat(ctx context.Context, chatID int64) error
+	ExportChat(ctx context.Context, chatID int64) (ChatExport, error)
+
+	SaveCalendarToken(ctx context.Context, chatID int64, token string) error
+	GetCalendarToken(ctx context.Context, chatID int64) (string, error)
+	GetChatIDbyCalendarToken(ctx context.Context, token string) (int64, error)
+	DeleteCalendarToken(ctx context.Context, chatID int64) error
+
+	SaveReminder(ctx context.Context, reminder Reminder) error
+	GetReminders(ctx context.Context, chatID int64) ([]Reminder, error)
+	GetDueReminders(ctx context.Context, now time.Time) ([]Reminder, error)
+	MarkReminderSent(ctx context.Context, id int64, sentAt time.Time) error
+	// DeleteSentReminders удаляет из истории уведомлений отправленные раньше before
+	DeleteSentReminders(ctx context.Context, before time.Time) (int64, error)
+	// RetryReminder откладывает неотправленное напоминание до retryAt и увеличивает Attempts
+	RetryReminder(ctx context.Context, id int64, retryAt time.Time) error
+	DeleteReminder(ctx context.Context, id int64) error
+	DeleteConcertReminders(ctx context.Context, chatID int64, concertID int) error
+}
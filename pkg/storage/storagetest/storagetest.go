@@ -0,0 +1,615 @@
+// Package storagetest содержит общий набор тестов поведения, который должна проходить любая реализация storage.Storage
+package storagetest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shakareem/gigoseek/pkg/storage"
+	"golang.org/x/oauth2"
+)
+
+// Run прогоняет контрактные тесты. newStorage вызывается перед каждым тестом и должен возвращать пустое хранилище
+func Run(t *testing.T, newStorage func(t *testing.T) storage.Storage) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s storage.Storage)
+	}{
+		{"AuthState", testAuthState},
+		{"Token", testToken},
+		{"City", testCity},
+		{"ChatState", testChatState},
+		{"Language", testLanguage},
+		{"CalendarToken", testCalendarToken},
+		{"Reminders", testReminders},
+		{"DeleteChat", testDeleteChat},
+		{"ExportChat", testExportChat},
+		{"Transaction", testTransaction},
+		{"Concurrent", testConcurrent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStorage(t))
+		})
+	}
+}
+
+const (
+	chatID      int64 = 1001
+	otherChatID int64 = 1002
+)
+
+// состояния диалога для хранилища просто строки, тесты используют те же названия, что и бот
+const (
+	waitingForCity storage.ChatState = "waiting_for_city"
+	waitingForAuth storage.ChatState = "waiting_for_auth"
+)
+
+// now обрезается до секунд, потому что postgres хранит время с точностью до микросекунд
+var now = time.Now().Truncate(time.Second)
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// registerChat создаёт запись чата: в postgres от неё зависят токены, город и календарь
+func registerChat(t *testing.T, s storage.Storage, chatID int64) {
+	t.Helper()
+	must(t, s.SaveChatState(t.Context(), chatID, storage.DefaultChatState))
+}
+
+func testAuthState(t *testing.T, s storage.Storage) {
+	ctx := t.Context()
+
+	must(t, s.SaveAuthState(ctx, storage.AuthState{State: "first", ChatID: chatID, CodeVerifier: "verifier"}))
+
+	authState, err := s.ConsumeAuthState(ctx, "first")
+	must(t, err)
+	if authState.ChatID != chatID || authState.CodeVerifier != "verifier" {
+		t.Errorf("ConsumeAuthState = %+v, want chat %d with verifier", authState, chatID)
+	}
+	if authState.CreatedAt.IsZero() {
+		t.Error("ConsumeAuthState returned zero CreatedAt")
+	}
+	if _, err := s.ConsumeAuthState(ctx, "first"); err == nil {
+		t.Error("state consumed twice")
+	}
+
+	must(t, s.SaveAuthState(ctx, storage.AuthState{State: "old", ChatID: chatID}))
+	must(t, s.SaveAuthState(ctx, storage.AuthState{State: "new", ChatID: chatID}))
+	if _, err := s.ConsumeAuthState(ctx, "old"); err == nil {
+		t.Error("older state of chat wasn't replaced")
+	}
+
+	must(t, s.SaveAuthState(ctx, storage.AuthState{State: "deleted", ChatID: otherChatID}))
+	must(t, s.DeleteAuthState(ctx, "deleted"))
+	if _, err := s.ConsumeAuthState(ctx, "deleted"); err == nil {
+		t.Error("deleted state consumed")
+	}
+
+	must(t, s.SaveAuthState(ctx, storage.AuthState{State: "logged out", ChatID: otherChatID}))
+	must(t, s.DeleteAuthStates(ctx, otherChatID))
+	if _, err := s.ConsumeAuthState(ctx, "logged out"); err == nil {
+		t.Error("state consumed after DeleteAuthStates")
+	}
+
+	deleted, err := s.DeleteExpiredAuthStates(ctx, time.Now().Add(time.Hour))
+	must(t, err)
+	if deleted != 1 {
+		t.Errorf("DeleteExpiredAuthStates deleted %d states, want 1", deleted)
+	}
+	if _, err := s.ConsumeAuthState(ctx, "new"); err == nil {
+		t.Error("expired state consumed")
+	}
+}
+
+func testToken(t *testing.T, s storage.Storage) {
+	ctx := t.Context()
+
+	registerChat(t, s, chatID)
+	registerChat(t, s, otherChatID)
+
+	if _, err := s.GetToken(ctx, chatID); err == nil {
+		t.Error("GetToken succeeded before SaveToken")
+	}
+	if err := s.SaveToken(ctx, 404, oauth2.Token{AccessToken: "a", RefreshToken: "r"}); err == nil {
+		t.Error("SaveToken accepted token of unknown chat")
+	}
+
+	token := oauth2.Token{AccessToken: "access", TokenType: "Bearer", RefreshToken: "refresh", Expiry: now.Add(time.Hour)}
+	must(t, s.SaveToken(ctx, chatID, token))
+	must(t, s.SaveToken(ctx, otherChatID, oauth2.Token{AccessToken: "a", TokenType: "Bearer", RefreshToken: "r", Expiry: now.Add(time.Minute)}))
+
+	got, err := s.GetToken(ctx, chatID)
+	must(t, err)
+	if got.AccessToken != token.AccessToken || got.RefreshToken != token.RefreshToken ||
+		got.TokenType != token.TokenType || !got.Expiry.Equal(token.Expiry) {
+		t.Errorf("GetToken = %+v, want %+v", got, token)
+	}
+
+	token.AccessToken = "refreshed"
+	must(t, s.SaveToken(ctx, chatID, token))
+	got, err = s.GetToken(ctx, chatID)
+	must(t, err)
+	if got.AccessToken != "refreshed" {
+		t.Errorf("SaveToken didn't overwrite token, got %q", got.AccessToken)
+	}
+
+	expiring, err := s.GetExpiringTokens(ctx, now.Add(10*time.Minute), now.Add(-time.Hour))
+	must(t, err)
+	if len(expiring) != 0 {
+		t.Errorf("GetExpiringTokens = %v, want none for chats without activity", expiring)
+	}
+
+	must(t, s.SaveChatActivity(ctx, chatID, now))
+	must(t, s.SaveChatActivity(ctx, otherChatID, now.Add(-2*time.Hour)))
+	expiring, err = s.GetExpiringTokens(ctx, now.Add(10*time.Minute), now.Add(-time.Hour))
+	must(t, err)
+	if len(expiring) != 0 {
+		t.Errorf("GetExpiringTokens = %v, want none for inactive chat", expiring)
+	}
+
+	must(t, s.SaveChatActivity(ctx, otherChatID, now))
+	expiring, err = s.GetExpiringTokens(ctx, now.Add(10*time.Minute), now.Add(-time.Hour))
+	must(t, err)
+	if !slices.Equal(expiring, []int64{otherChatID}) {
+		t.Errorf("GetExpiringTokens = %v, want [%d]", expiring, otherChatID)
+	}
+
+	must(t, s.DeleteToken(ctx, chatID))
+	if _, err := s.GetToken(ctx, chatID); err == nil {
+		t.Error("GetToken succeeded after DeleteToken")
+	}
+}
+
+func testCity(t *testing.T, s storage.Storage) {
+	ctx := t.Context()
+
+	registerChat(t, s, chatID)
+
+	// справочник из миграций: в каждом хранилище есть одни и те же города с синонимами
+	spb, err := s.FindCity(ctx, "питер")
+	must(t, err)
+	if spb.Name != "Санкт-Петербург" || spb.Timezone != "Europe/Moscow" || !slices.Contains(spb.Aliases, "spb") {
+		t.Errorf("FindCity(питер) = %+v, want Санкт-Петербург from the catalogue", spb)
+	}
+	if city, err := s.FindCity(ctx, "Анадырь"); err != nil || city.Timezone != "Asia/Anadyr" {
+		t.Errorf("FindCity(Анадырь) = %+v, %v, want the catalogue city", city, err)
+	}
+	must(t, s.SaveCity(ctx, chatID, "спб"))
+	if city, err := s.GetCity(ctx, chatID); err != nil || city.ID != spb.ID {
+		t.Errorf("GetCity after SaveCity(спб) = %+v, %v", city, err)
+	}
+	must(t, s.DeleteCity(ctx, chatID))
+
+	added, err := s.AddCity(ctx, storage.City{
+		Name:        "Contract Город",
+		TimepadName: "Contract Timepad",
+		Aliases:     []string{"Contract Alias", "contract-city"},
+		Latitude:    55.5,
+		Longitude:   37.5,
+		Timezone:    "Europe/Moscow",
+		Population:  1000,
+	})
+	must(t, err)
+	if added.ID == 0 {
+		t.Error("AddCity didn't assign ID")
+	}
+
+	if _, err := s.AddCity(ctx, storage.City{Name: "Contract Город", TimepadName: "x", Timezone: "Europe/Moscow"}); err == nil {
+		t.Error("AddCity accepted duplicate name")
+	}
+	if _, err := s.AddCity(ctx, storage.City{Name: "Contract Other", TimepadName: "x", Timezone: "Europe/Moscow", Aliases: []string{"contract alias"}}); err == nil {
+		t.Error("AddCity accepted alias of another city")
+	}
+
+	for _, name := range []string{"Contract Город", "contract город", "CONTRACT ALIAS", "  contract-city "} {
+		found, err := s.FindCity(ctx, name)
+		if err != nil {
+			t.Errorf("FindCity(%q): %v", name, err)
+			continue
+		}
+		if found.ID != added.ID || found.TimepadName != "Contract Timepad" || found.Population != 1000 {
+			t.Errorf("FindCity(%q) = %+v", name, found)
+		}
+	}
+	if _, err := s.FindCity(ctx, "Contract Nowhere"); !errors.Is(err, storage.ErrCityNotFound) {
+		t.Errorf("FindCity of unknown city: %v, want ErrCityNotFound", err)
+	}
+
+	if _, err := s.GetCity(ctx, chatID); err == nil {
+		t.Error("GetCity succeeded before SaveCity")
+	}
+	if err := s.SaveCity(ctx, chatID, "Contract Nowhere"); !errors.Is(err, storage.ErrCityNotFound) {
+		t.Errorf("SaveCity of unknown city: %v, want ErrCityNotFound", err)
+	}
+	if err := s.SaveCity(ctx, otherChatID, "contract alias"); !errors.Is(err, storage.ErrChatNotFound) {
+		t.Errorf("SaveCity for unknown chat: %v, want ErrChatNotFound", err)
+	}
+	must(t, s.SaveCity(ctx, chatID, "contract alias"))
+
+	city, err := s.GetCity(ctx, chatID)
+	must(t, err)
+	if city.Name != "Contract Город" || city.Timezone != "Europe/Moscow" {
+		t.Errorf("GetCity = %+v", city)
+	}
+
+	city.Population = 2000
+	city.Aliases = []string{"Contract Renamed"}
+	must(t, s.UpdateCity(ctx, city))
+	if _, err := s.FindCity(ctx, "contract alias"); !errors.Is(err, storage.ErrCityNotFound) {
+		t.Errorf("removed alias still resolves: %v", err)
+	}
+	found, err := s.FindCity(ctx, "contract renamed")
+	must(t, err)
+	if found.Population != 2000 || !slices.Equal(found.Aliases, []string{"contract renamed"}) {
+		t.Errorf("FindCity after UpdateCity = %+v", found)
+	}
+
+	if err := s.UpdateCity(ctx, storage.City{ID: -1, Name: "Contract Ghost"}); !errors.Is(err, storage.ErrCityNotFound) {
+		t.Errorf("UpdateCity of unknown city: %v, want ErrCityNotFound", err)
+	}
+
+	must(t, s.DeleteCity(ctx, chatID))
+	if _, err := s.GetCity(ctx, chatID); err == nil {
+		t.Error("GetCity succeeded after DeleteCity")
+	}
+}
+
+func testChatState(t *testing.T, s storage.Storage) {
+	ctx := t.Context()
+
+	if _, err := s.GetConversation(ctx, chatID); !errors.Is(err, storage.ErrChatNotFound) {
+		t.Errorf("GetConversation for unknown chat: %v, want ErrChatNotFound", err)
+	}
+
+	must(t, s.SaveChatState(ctx, chatID, waitingForCity))
+	conv, err := s.GetConversation(ctx, chatID)
+	must(t, err)
+	if conv.State != waitingForCity || conv.Payload != nil || conv.UpdatedAt.IsZero() {
+		t.Errorf("GetConversation = %+v, want %q without payload", conv, waitingForCity)
+	}
+
+	must(t, s.SaveConversation(ctx, chatID, storage.Conversation{
+		State:     waitingForCity,
+		Payload:   json.RawMessage(`{"then":"concerts"}`),
+		UpdatedAt: now,
+	}))
+	conv, err = s.GetConversation(ctx, chatID)
+	must(t, err)
+	var payload struct{ Then string }
+	must(t, json.Unmarshal(conv.Payload, &payload))
+	if payload.Then != "concerts" || !conv.UpdatedAt.Equal(now) {
+		t.Errorf("GetConversation = %+v, want saved payload and time", conv)
+	}
+
+	must(t, s.DeleteChatState(ctx, chatID))
+	conv, err = s.GetConversation(ctx, chatID)
+	must(t, err)
+	if conv.State != storage.DefaultChatState || conv.Payload != nil {
+		t.Errorf("GetConversation after DeleteChatState = %+v, want %q without payload", conv, storage.DefaultChatState)
+	}
+}
+
+func testLanguage(t *testing.T, s storage.Storage) {
+	ctx := t.Context()
+
+	if _, err := s.GetLanguage(ctx, chatID); !errors.Is(err, storage.ErrChatNotFound) {
+		t.Errorf("GetLanguage for unknown chat: %v, want ErrChatNotFound", err)
+	}
+	if err := s.SaveLanguage(ctx, chatID, "en"); !errors.Is(err, storage.ErrChatNotFound) {
+		t.Errorf("SaveLanguage for unknown chat: %v, want ErrChatNotFound", err)
+	}
+
+	registerChat(t, s, chatID)
+	lang, err := s.GetLanguage(ctx, chatID)
+	must(t, err)
+	if lang != "" {
+		t.Errorf("GetLanguage before SaveLanguage = %q, want empty", lang)
+	}
+
+	must(t, s.SaveLanguage(ctx, chatID, "ru"))
+	must(t, s.SaveLanguage(ctx, chatID, "en"))
+	lang, err = s.GetLanguage(ctx, chatID)
+	must(t, err)
+	if lang != "en" {
+		t.Errorf("GetLanguage = %q, want %q", lang, "en")
+	}
+
+	// смена состояния диалога не сбрасывает язык
+	must(t, s.DeleteChatState(ctx, chatID))
+	if lang, _ := s.GetLanguage(ctx, chatID); lang != "en" {
+		t.Errorf("GetLanguage after DeleteChatState = %q, want %q", lang, "en")
+	}
+}
+
+func testCalendarToken(t *testing.T, s storage.Storage) {
+	ctx := t.Context()
+
+	registerChat(t, s, chatID)
+
+	if _, err := s.GetCalendarToken(ctx, chatID); err == nil {
+		t.Error("GetCalendarToken succeeded before SaveCalendarToken")
+	}
+
+	must(t, s.SaveCalendarToken(ctx, chatID, "old"))
+	must(t, s.SaveCalendarToken(ctx, chatID, "feed"))
+
+	token, err := s.GetCalendarToken(ctx, chatID)
+	must(t, err)
+	if token != "feed" {
+		t.Errorf("GetCalendarToken = %q, want %q", token, "feed")
+	}
+
+	id, err := s.GetChatIDbyCalendarToken(ctx, "feed")
+	must(t, err)
+	if id != chatID {
+		t.Errorf("GetChatIDbyCalendarToken = %d, want %d", id, chatID)
+	}
+	if _, err := s.GetChatIDbyCalendarToken(ctx, "old"); err == nil {
+		t.Error("replaced calendar token still resolves")
+	}
+
+	must(t, s.DeleteCalendarToken(ctx, chatID))
+	if _, err := s.GetChatIDbyCalendarToken(ctx, "feed"); err == nil {
+		t.Error("deleted calendar token still resolves")
+	}
+}
+
+func reminder(chatID int64, concertID int, remindAt time.Time) storage.Reminder {
+	return storage.Reminder{
+		ChatID:      chatID,
+		ConcertID:   concertID,
+		ConcertName: fmt.Sprintf("concert %d", concertID),
+		ConcertURL:  fmt.Sprintf("https://example.com/%d", concertID),
+		StartsAt:    now.Add(24 * time.Hour),
+		RemindAt:    remindAt,
+	}
+}
+
+func concertIDs(reminders []storage.Reminder) []int {
+	ids := make([]int, len(reminders))
+	for i, r := range reminders {
+		ids[i] = r.ConcertID
+	}
+	return ids
+}
+
+func testReminders(t *testing.T, s storage.Storage) {
+	ctx := t.Context()
+
+	registerChat(t, s, chatID)
+	registerChat(t, s, otherChatID)
+
+	must(t, s.SaveReminder(ctx, reminder(chatID, 2, now.Add(2*time.Hour))))
+	must(t, s.SaveReminder(ctx, reminder(chatID, 1, now.Add(-time.Minute))))
+	must(t, s.SaveReminder(ctx, reminder(chatID, 1, now.Add(-time.Minute))))
+	must(t, s.SaveReminder(ctx, reminder(otherChatID, 3, now.Add(-time.Hour))))
+
+	reminders, err := s.GetReminders(ctx, chatID)
+	must(t, err)
+	if ids := concertIDs(reminders); !slices.Equal(ids, []int{1, 2}) {
+		t.Fatalf("GetReminders = %v, want [1 2] ordered by remind_at without duplicates", ids)
+	}
+	if r := reminders[1]; r.ConcertName != "concert 2" || !r.StartsAt.Equal(now.Add(24*time.Hour)) || !r.RemindAt.Equal(now.Add(2*time.Hour)) {
+		t.Errorf("GetReminders returned %+v", r)
+	}
+
+	due, err := s.GetDueReminders(ctx, now)
+	must(t, err)
+	if ids := concertIDs(due); !slices.Equal(ids, []int{3, 1}) {
+		t.Fatalf("GetDueReminders = %v, want [3 1]", ids)
+	}
+
+	must(t, s.MarkReminderSent(ctx, due[1].ID, now))
+	due, err = s.GetDueReminders(ctx, now)
+	must(t, err)
+	if ids := concertIDs(due); !slices.Equal(ids, []int{3}) {
+		t.Errorf("GetDueReminders after MarkReminderSent = %v, want [3]", ids)
+	}
+
+	must(t, s.DeleteConcertReminders(ctx, chatID, 1))
+	must(t, s.DeleteConcertReminders(ctx, chatID, 2))
+	reminders, err = s.GetReminders(ctx, chatID)
+	must(t, err)
+	if len(reminders) != 0 {
+		t.Errorf("GetReminders after DeleteConcertReminders = %v, want none", concertIDs(reminders))
+	}
+
+	export, err := s.ExportChat(ctx, chatID)
+	must(t, err)
+	if ids := concertIDs(export.Notifications); !slices.Equal(ids, []int{1}) {
+		t.Errorf("sent reminder wasn't kept as notification, got %v", ids)
+	}
+
+	deleted, err := s.DeleteSentReminders(ctx, now)
+	must(t, err)
+	if deleted != 0 {
+		t.Errorf("DeleteSentReminders deleted %d notifications sent at the cutoff, want 0", deleted)
+	}
+	deleted, err = s.DeleteSentReminders(ctx, now.Add(time.Second))
+	must(t, err)
+	if deleted != 1 {
+		t.Errorf("DeleteSentReminders deleted %d notifications, want 1", deleted)
+	}
+
+	must(t, s.RetryReminder(ctx, due[0].ID, now.Add(time.Hour)))
+	if retried, err := s.GetDueReminders(ctx, now); err != nil || len(retried) != 0 {
+		t.Errorf("GetDueReminders before retry time = %v, %v, want none", concertIDs(retried), err)
+	}
+	retried, err := s.GetDueReminders(ctx, now.Add(time.Hour))
+	must(t, err)
+	if len(retried) != 1 || retried[0].Attempts != 1 || !retried[0].DueAt().Equal(now.Add(time.Hour)) {
+		t.Errorf("GetDueReminders at retry time = %+v, want reminder 3 with 1 attempt", retried)
+	}
+
+	must(t, s.DeleteReminder(ctx, due[0].ID))
+	due, err = s.GetDueReminders(ctx, now)
+	must(t, err)
+	if len(due) != 0 {
+		t.Errorf("GetDueReminders after DeleteReminder = %v, want none", concertIDs(due))
+	}
+}
+
+func testDeleteChat(t *testing.T, s storage.Storage) {
+	ctx := t.Context()
+
+	registerChat(t, s, chatID)
+	registerChat(t, s, otherChatID)
+
+	for _, id := range []int64{chatID, otherChatID} {
+		must(t, s.SaveAuthState(ctx, storage.AuthState{State: fmt.Sprint("state", id), ChatID: id}))
+		must(t, s.SaveToken(ctx, id, oauth2.Token{AccessToken: "a", TokenType: "Bearer", RefreshToken: "r", Expiry: now}))
+		must(t, s.SaveCalendarToken(ctx, id, fmt.Sprint("feed", id)))
+		must(t, s.SaveReminder(ctx, reminder(id, 1, now)))
+	}
+
+	must(t, s.DeleteChat(ctx, chatID))
+
+	if _, err := s.GetToken(ctx, chatID); err == nil {
+		t.Error("token survived DeleteChat")
+	}
+	if _, err := s.GetConversation(ctx, chatID); err == nil {
+		t.Error("chat state survived DeleteChat")
+	}
+	if _, err := s.GetCalendarToken(ctx, chatID); err == nil {
+		t.Error("calendar token survived DeleteChat")
+	}
+	if _, err := s.ConsumeAuthState(ctx, fmt.Sprint("state", chatID)); err == nil {
+		t.Error("auth state survived DeleteChat")
+	}
+	if reminders, _ := s.GetReminders(ctx, chatID); len(reminders) != 0 {
+		t.Error("reminders survived DeleteChat")
+	}
+
+	if _, err := s.GetToken(ctx, otherChatID); err != nil {
+		t.Errorf("DeleteChat removed token of another chat: %v", err)
+	}
+	if reminders, _ := s.GetReminders(ctx, otherChatID); len(reminders) != 1 {
+		t.Error("DeleteChat removed reminders of another chat")
+	}
+}
+
+func testExportChat(t *testing.T, s storage.Storage) {
+	ctx := t.Context()
+
+	export, err := s.ExportChat(ctx, chatID)
+	must(t, err)
+	if export.Spotify != nil || export.CalendarFeed || len(export.Reminders) != 0 || export.Reminders == nil {
+		t.Errorf("ExportChat of unknown chat = %+v, want empty export with non-nil lists", export)
+	}
+
+	registerChat(t, s, chatID)
+	must(t, s.SaveChatState(ctx, chatID, waitingForCity))
+	must(t, s.SaveLanguage(ctx, chatID, "en"))
+	must(t, s.SaveAuthState(ctx, storage.AuthState{State: "export", ChatID: chatID}))
+	must(t, s.SaveToken(ctx, chatID, oauth2.Token{AccessToken: "secret", TokenType: "Bearer", RefreshToken: "secret", Expiry: now}))
+	must(t, s.SaveCalendarToken(ctx, chatID, "export-feed"))
+	must(t, s.SaveReminder(ctx, reminder(chatID, 7, now.Add(time.Hour))))
+
+	export, err = s.ExportChat(ctx, chatID)
+	must(t, err)
+	if export.ChatID != chatID || export.ChatState != waitingForCity || export.Language != "en" || !export.CalendarFeed || export.PendingAuth != 1 {
+		t.Errorf("ExportChat = %+v", export)
+	}
+	if export.Spotify == nil || export.Spotify.TokenType != "Bearer" || !export.Spotify.TokenExpiry.Equal(now) {
+		t.Errorf("ExportChat linked account = %+v", export.Spotify)
+	}
+	if ids := concertIDs(export.Reminders); !slices.Equal(ids, []int{7}) {
+		t.Errorf("ExportChat reminders = %v, want [7]", ids)
+	}
+}
+
+// testConcurrent имеет смысл с -race: обработчики бота обращаются к хранилищу из разных горутин
+func testTransaction(t *testing.T, s storage.Storage) {
+	ctx := t.Context()
+	token := oauth2.Token{AccessToken: "access", TokenType: "Bearer", RefreshToken: "refresh", Expiry: now}
+
+	must(t, s.WithTx(ctx, func(tx storage.Storage) error {
+		if err := tx.SaveChatState(ctx, chatID, storage.DefaultChatState); err != nil {
+			return err
+		}
+		// вложенная транзакция присоединяется к внешней
+		return tx.WithTx(ctx, func(tx storage.Storage) error {
+			return tx.SaveToken(ctx, chatID, token)
+		})
+	}))
+	if _, err := s.GetToken(ctx, chatID); err != nil {
+		t.Errorf("token saved in committed transaction not found: %v", err)
+	}
+
+	errAbort := errors.New("abort")
+	err := s.WithTx(ctx, func(tx storage.Storage) error {
+		if err := tx.DeleteToken(ctx, chatID); err != nil {
+			return err
+		}
+		if err := tx.SaveChatState(ctx, chatID, waitingForAuth); err != nil {
+			return err
+		}
+		if _, err := tx.GetToken(ctx, chatID); err == nil {
+			t.Error("transaction doesn't see its own DeleteToken")
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Errorf("WithTx = %v, want error returned by fn", err)
+	}
+
+	if _, err := s.GetToken(ctx, chatID); err != nil {
+		t.Errorf("token deleted in rolled back transaction: %v", err)
+	}
+	conv, err := s.GetConversation(ctx, chatID)
+	must(t, err)
+	if conv.State != storage.DefaultChatState {
+		t.Errorf("chat state = %q after rollback, want %q", conv.State, storage.DefaultChatState)
+	}
+}
+
+func testConcurrent(t *testing.T, s storage.Storage) {
+	ctx := t.Context()
+
+	const workers = 8
+
+	var wg sync.WaitGroup
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id := chatID + int64(i)
+			for j := range 20 {
+				if err := s.SaveChatState(ctx, id, []storage.ChatState{storage.DefaultChatState, waitingForCity, waitingForAuth}[j%3]); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := s.GetConversation(ctx, id); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := s.SaveReminder(ctx, reminder(id, j, now)); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := s.GetDueReminders(ctx, now); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	due, err := s.GetDueReminders(ctx, now)
+	must(t, err)
+	if len(due) != workers*20 {
+		t.Errorf("GetDueReminders = %d reminders, want %d", len(due), workers*20)
+	}
+}
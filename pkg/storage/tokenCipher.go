@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// plaintextKeyID помечает строки, записанные до появления шифрования
+const plaintextKeyID = ""
+
+// TokenCipher шифрует поля токенов с помощью AES-256-GCM.
+// Ключи задаются строкой вида "id2:base64key,id1:base64key": первым ключом шифруются новые записи,
+// остальные нужны, чтобы читать записи, ещё не перешифрованные после ротации
+type TokenCipher struct {
+	activeID string
+	keys     map[string]cipher.AEAD
+}
+
+func NewTokenCipher(keys string) (*TokenCipher, error) {
+	c := &TokenCipher{keys: make(map[string]cipher.AEAD)}
+
+	for i, entry := range strings.Split(keys, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == plaintextKeyID {
+			return nil, fmt.Errorf("key %d: expected format id:base64key", i+1)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("key %q: expected 32 bytes, got %d", id, len(key))
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+
+		if _, exists := c.keys[id]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", id)
+		}
+		c.keys[id] = aead
+		if c.activeID == "" {
+			c.activeID = id
+		}
+	}
+
+	if c.activeID == "" {
+		return nil, errors.New("no token encryption keys configured")
+	}
+
+	return c, nil
+}
+
+func (c *TokenCipher) ActiveKeyID() string {
+	return c.activeID
+}
+
+// Encrypt шифрует значение активным ключом. chatID и название поля входят в
+// дополнительные данные, поэтому зашифрованное значение нельзя подставить в другую строку или колонку
+func (c *TokenCipher) Encrypt(chatID int64, field, plaintext string) (string, error) {
+	aead := c.keys[c.activeID]
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), additionalData(chatID, field))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *TokenCipher) Decrypt(keyID string, chatID int64, field, ciphertext string) (string, error) {
+	if keyID == plaintextKeyID {
+		return ciphertext, nil
+	}
+
+	aead, ok := c.keys[keyID]
+	if !ok {
+		return "", fmt.Errorf("unknown token encryption key %q", keyID)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("ciphertext too short")
+	}
+
+	nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, additionalData(chatID, field))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s: %w", field, err)
+	}
+
+	return string(plaintext), nil
+}
+
+func additionalData(chatID int64, field string) []byte {
+	return []byte(strconv.FormatInt(chatID, 10) + ":" + field)
+}
+
+const (
+	accessTokenField  = "access_token"
+	refreshTokenField = "refresh_token"
+)
+
+type encryptedToken struct {
+	KeyID        string
+	AccessToken  string
+	RefreshToken string
+}
+
+func (c *TokenCipher) encryptToken(chatID int64, accessToken, refreshToken string) (encryptedToken, error) {
+	access, err := c.Encrypt(chatID, accessTokenField, accessToken)
+	if err != nil {
+		return encryptedToken{}, err
+	}
+
+	refresh, err := c.Encrypt(chatID, refreshTokenField, refreshToken)
+	if err != nil {
+		return encryptedToken{}, err
+	}
+
+	return encryptedToken{KeyID: c.activeID, AccessToken: access, RefreshToken: refresh}, nil
+}
+
+func (c *TokenCipher) decryptToken(chatID int64, token encryptedToken) (accessToken, refreshToken string, err error) {
+	accessToken, err = c.Decrypt(token.KeyID, chatID, accessTokenField, token.AccessToken)
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshToken, err = c.Decrypt(token.KeyID, chatID, refreshTokenField, token.RefreshToken)
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, refreshToken, nil
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+func TestTokenCipherRoundTrip(t *testing.T) {
+	c, err := NewTokenCipher("1:" + testKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := c.Encrypt(42, accessTokenField, "secret-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encrypted == "secret-token" {
+		t.Fatal("Encrypt returned the plaintext")
+	}
+
+	again, err := c.Encrypt(42, accessTokenField, "secret-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == encrypted {
+		t.Error("two encryptions of the same value are equal, nonce isn't random")
+	}
+
+	decrypted, err := c.Decrypt(c.ActiveKeyID(), 42, accessTokenField, encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted != "secret-token" {
+		t.Errorf("Decrypt = %q, want %q", decrypted, "secret-token")
+	}
+}
+
+func TestTokenCipherRotation(t *testing.T) {
+	old, err := NewTokenCipher("1:" + testKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := old.Encrypt(42, refreshTokenField, "refresh")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := NewTokenCipher("2:" + testKey(2) + ", 1:" + testKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.ActiveKeyID() != "2" {
+		t.Errorf("ActiveKeyID = %q, want the first key", rotated.ActiveKeyID())
+	}
+
+	decrypted, err := rotated.Decrypt("1", 42, refreshTokenField, encrypted)
+	if err != nil {
+		t.Fatalf("old key can't decrypt after rotation: %v", err)
+	}
+	if decrypted != "refresh" {
+		t.Errorf("Decrypt = %q, want %q", decrypted, "refresh")
+	}
+
+	withoutOld, err := NewTokenCipher("2:" + testKey(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := withoutOld.Decrypt("1", 42, refreshTokenField, encrypted); err == nil {
+		t.Error("Decrypt with an unknown key id succeeded")
+	}
+}
+
+func TestTokenCipherAdditionalData(t *testing.T) {
+	c, err := NewTokenCipher("1:" + testKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := c.Encrypt(42, accessTokenField, "secret-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Decrypt("1", 43, accessTokenField, encrypted); err == nil {
+		t.Error("value encrypted for chat 42 was decrypted for chat 43")
+	}
+	if _, err := c.Decrypt("1", 42, refreshTokenField, encrypted); err == nil {
+		t.Error("access token was decrypted as refresh token")
+	}
+	if _, err := c.Decrypt("1", 42, accessTokenField, "c2hvcnQ="); err == nil {
+		t.Error("too short ciphertext was decrypted")
+	}
+
+	plaintext, err := c.Decrypt(plaintextKeyID, 42, accessTokenField, "legacy")
+	if err != nil || plaintext != "legacy" {
+		t.Errorf("rows written before encryption must be read as is, got %q, %v", plaintext, err)
+	}
+}
+
+func TestNewTokenCipherInvalidKeys(t *testing.T) {
+	tests := map[string]string{
+		"empty":      "",
+		"no id":      testKey(1),
+		"bad base64": "1:not base64",
+		"short key":  "1:" + base64.StdEncoding.EncodeToString([]byte("short")),
+		"duplicate":  "1:" + testKey(1) + ",1:" + testKey(2),
+		"empty id":   ":" + testKey(1),
+	}
+
+	for name, keys := range tests {
+		if _, err := NewTokenCipher(keys); err == nil {
+			t.Errorf("%s: NewTokenCipher(%q) succeeded", name, keys)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const deleteMeCallback = "delete_me"
+
+// handleLogout отзывает всё, что даёт доступ к данным Spotify: токен, неиспользованные ссылки
+// для авторизации и ссылку на календарь, которая строится по любимым артистам
+func (b *Bot) handleLogout(ctx context.Context, chatID int64) error {
+	err := b.storage.WithTx(ctx, func(tx Storage) error {
+		if err := tx.DeleteToken(ctx, chatID); err != nil {
+			return err
+		}
+		if err := tx.DeleteAuthStates(ctx, chatID); err != nil {
+			return err
+		}
+		if err := tx.DeleteCalendarToken(ctx, chatID); err != nil {
+			return err
+		}
+		return transition(ctx, tx, chatID, StateIdle, nil)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to log out chat %d: %w", chatID, err)
+	}
+
+	log.Printf("Chat %d logged out", chatID)
+	return b.sendMessage(ctx, chatID, messages(ctx).LogoutSuccess)
+}
+
+func (b *Bot) handleDeleteMe(ctx context.Context, chatID int64) error {
+	msg := tgbotapi.NewMessage(chatID, messages(ctx).DeleteMeConfirm)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(messages(ctx).DeleteMeButton, deleteMeCallback),
+		),
+	)
+	_, err := b.sender.Send(ctx, chatID, msg)
+	return err
+}
+
+func (b *Bot) handleDeleteMeConfirmed(ctx context.Context, chatID int64) error {
+	if err := b.storage.DeleteChat(ctx, chatID); err != nil {
+		return fmt.Errorf("failed to delete data of chat %d: %w", chatID, err)
+	}
+
+	log.Printf("All data of chat %d deleted", chatID)
+	return b.sendMessage(ctx, chatID, messages(ctx).DeleteMeSuccess)
+}
@@ -3,21 +3,25 @@ package telegram
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/shakareem/gigoseek/pkg/config"
-	"github.com/zmb3/spotify/v2"
 	spotifyauth "github.com/zmb3/spotify/v2/auth"
 	"golang.org/x/oauth2"
 )
 
-var (
-	redirectURL = config.Get().AuthServerURL
-	auth        = spotifyauth.New(
-		spotifyauth.WithRedirectURL(redirectURL),
+// spotifyAuth создаётся при первом использовании, а не при инициализации пакета,
+// чтобы конфиг читался уже после того, как main или тесты укажут путь к нему
+var spotifyAuth = sync.OnceValue(func() *spotifyauth.Authenticator {
+	return spotifyauth.New(
+		spotifyauth.WithRedirectURL(config.Get().AuthServerURL),
 		spotifyauth.WithScopes(
 			spotifyauth.ScopeUserLibraryRead,
 			spotifyauth.ScopeUserFollowRead,
@@ -26,7 +30,12 @@ var (
 		spotifyauth.WithClientID(config.Get().SpotifyClientID),
 		spotifyauth.WithClientSecret(config.Get().SpotifyClientSecret),
 	)
-	userInfoChan = make(chan userInfo)
+})
+
+const (
+	// authStateTTL сколько действует ссылка для авторизации
+	authStateTTL             = 15 * time.Minute
+	authStateCleanupInterval = time.Hour
 )
 
 func generateState() string {
@@ -38,71 +47,168 @@ func generateState() string {
 	return base64.URLEncoding.EncodeToString(b)
 }
 
+// generateCodeVerifier создаёт code_verifier для PKCE (RFC 7636): 43 символа base64url без паддинга
+func generateCodeVerifier() string {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
 type AuthServer struct {
-	server      *http.Server
-	storage     Storage
-	authUpdates chan<- int64
+	server           *http.Server
+	mux              *http.ServeMux
+	storage          Storage
+	concertsProvider ConcertsProvider
+	authUpdates      chan<- AuthResult
 }
 
-type userInfo struct {
-	chatID int64
-	token  *oauth2.Token
+// AuthResult сообщает боту, чем закончилась авторизация в чате
+type AuthResult struct {
+	ChatID  int64
+	Success bool
+	Denied  bool
 }
 
-func NewAuthServer(storage Storage, authUpdates chan<- int64) *AuthServer {
+func NewAuthServer(storage Storage, concertsProvider ConcertsProvider, authUpdates chan<- AuthResult) *AuthServer {
 	return &AuthServer{
-		storage:     storage,
-		authUpdates: authUpdates,
+		mux:              http.NewServeMux(),
+		storage:          storage,
+		concertsProvider: concertsProvider,
+		authUpdates:      authUpdates,
 	}
 }
 
-func (s *AuthServer) Run() error {
-	handler := http.NewServeMux()
-	handler.HandleFunc("/callback", s.completeAuth)
+// Handle добавляет обработчик на тот же http сервер, нужно вызывать до Run
+func (s *AuthServer) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Run запускает http сервер и останавливает его после отмены ctx
+func (s *AuthServer) Run(ctx context.Context) error {
+	s.mux.HandleFunc("/callback", s.completeAuth)
+	s.mux.HandleFunc(calendarPath, s.serveCalendar)
+
+	// запросы не отменяются по сигналу: Shutdown даёт начатым авторизациям завершиться и уведомить бота
+	baseCtx := context.WithoutCancel(ctx)
+	s.server = &http.Server{
+		Addr:        ":8080",
+		Handler:     s.mux,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
 
-	s.server = &http.Server{Addr: ":8080", Handler: handler}
+	go s.cleanupAuthStates(ctx)
 
+	errs := make(chan error, 1)
 	go func() {
-		for userInfo := range userInfoChan {
-			client := spotify.New(auth.Client(context.Background(), userInfo.token))
-
-			user, err := client.CurrentUser(context.Background())
-			if err != nil {
-				log.Fatal(err)
-			}
-			fmt.Println(
-				"New user logged in:", user.DisplayName,
-				"\nchatID:", userInfo.chatID,
-				"\ntoken:", userInfo.token.AccessToken,
-			)
-		}
+		log.Printf("Starting auth server on %v", config.Get().AuthServerURL)
+		errs <- s.server.ListenAndServe()
 	}()
 
-	log.Printf("Starting auth server on %v", redirectURL)
-	return s.server.ListenAndServe()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("Stopping auth server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+	defer cancel()
+
+	if err := s.server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shutdown auth server: %w", err)
+	}
+
+	return nil
 }
 
 func (s *AuthServer) completeAuth(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
 	receivedState := r.FormValue("state")
 
-	chatID, err := s.storage.GetChatIDbyAuthState(receivedState)
+	authState, err := s.storage.ConsumeAuthState(ctx, receivedState)
 	if err != nil {
 		log.Printf("HTTP request to auth server with invalid state: %v", receivedState)
-		http.Error(w, "Invalid state", http.StatusBadRequest)
+		renderAuthPage(w, http.StatusBadRequest, authPageInvalidState, s.authPageMessages(ctx, r, 0))
 		return
 	}
+	chatID := authState.ChatID
+	m := s.authPageMessages(ctx, r, chatID)
 
-	token, err := auth.Token(r.Context(), receivedState, r)
+	if authState.Expired(authStateTTL, time.Now()) {
+		log.Printf("HTTP request to auth server with expired state for chat %d", chatID)
+		renderAuthPage(w, http.StatusBadRequest, authPageInvalidState, m)
+		return
+	}
+
+	if authErr := r.FormValue("error"); authErr != "" {
+		log.Printf("Spotify auth for chat %d failed: %s", chatID, authErr)
+
+		denied := authErr == accessDeniedError
+		s.notifyAuthResult(ctx, AuthResult{ChatID: chatID, Denied: denied})
+
+		if denied {
+			renderAuthPage(w, http.StatusForbidden, authPageDenied, m)
+		} else {
+			renderAuthPage(w, http.StatusBadGateway, authPageFail, m)
+		}
+		return
+	}
+
+	token, err := spotifyAuth().Token(ctx, receivedState, r,
+		oauth2.SetAuthURLParam("code_verifier", authState.CodeVerifier),
+	)
 	if err != nil {
-		http.Error(w, "Couldn't get token", http.StatusForbidden)
-		log.Println("Failed to get token from state during auth")
+		log.Printf("Failed to exchange code for token for chat %d: %v", chatID, err)
+		s.notifyAuthResult(ctx, AuthResult{ChatID: chatID})
+		renderAuthPage(w, http.StatusBadGateway, authPageFail, m)
+		return
 	}
 
-	s.storage.SaveToken(chatID, *token)
-	s.storage.DeleteAuthState(receivedState)
-	userInfoChan <- userInfo{chatID: chatID, token: token}
+	if err := s.storage.SaveToken(ctx, chatID, *token); err != nil {
+		log.Printf("Failed to save token for chat %d: %v", chatID, err)
+		s.notifyAuthResult(ctx, AuthResult{ChatID: chatID})
+		renderAuthPage(w, http.StatusInternalServerError, authPageFail, m)
+		return
+	}
 
-	s.authUpdates <- chatID
+	s.notifyAuthResult(ctx, AuthResult{ChatID: chatID, Success: true})
+	renderAuthPage(w, http.StatusOK, authPageSuccess, m)
+}
 
-	http.Redirect(w, r, "https://web.telegram.org/k/#@gigoseek_bot", http.StatusSeeOther)
+// cleanupAuthStates периодически удаляет состояния, по которым так и не вернулись
+func (s *AuthServer) cleanupAuthStates(ctx context.Context) {
+	ticker := time.NewTicker(authStateCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			deleted, err := s.storage.DeleteExpiredAuthStates(ctx, now.Add(-authStateTTL))
+			if err != nil {
+				log.Printf("Failed to delete expired auth states: %v", err)
+				continue
+			}
+			if deleted > 0 {
+				log.Printf("Deleted %d expired auth states", deleted)
+			}
+		}
+	}
+}
+
+func (s *AuthServer) notifyAuthResult(ctx context.Context, result AuthResult) {
+	select {
+	case s.authUpdates <- result:
+	case <-ctx.Done():
+	}
 }
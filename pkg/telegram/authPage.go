@@ -0,0 +1,93 @@
+package telegram
+
+import (
+	"context"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/shakareem/gigoseek/pkg/config"
+	"github.com/shakareem/gigoseek/pkg/i18n"
+)
+
+// accessDeniedError spotify передаёт в параметре error, если пользователь отказался дать доступ
+const accessDeniedError = "access_denied"
+
+type authPageKind int
+
+const (
+	authPageSuccess authPageKind = iota
+	authPageFail
+	authPageDenied
+	authPageInvalidState
+)
+
+var authPageTemplate = template.Must(template.New("auth").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>gigoseek</title>
+<style>
+body { font-family: sans-serif; text-align: center; margin: 15vh 1em; }
+a { display: inline-block; margin-top: 1em; padding: .6em 1.2em; border-radius: .4em; background: #2aabee; color: #fff; text-decoration: none; }
+</style>
+</head>
+<body>
+<h1>{{.Icon}}</h1>
+<p>{{.Text}}</p>
+<a href="{{.BotURL}}">{{.BackToBot}}</a>
+</body>
+</html>
+`))
+
+type authPage struct {
+	Lang      i18n.Lang
+	Icon      string
+	Text      string
+	BotURL    string
+	BackToBot string
+}
+
+// authPageMessages страница показывается на языке чата, а если чат неизвестен - на языке браузера
+func (s *AuthServer) authPageMessages(ctx context.Context, r *http.Request, chatID int64) *i18n.Messages {
+	if chatID != 0 {
+		if saved, err := s.storage.GetLanguage(ctx, chatID); err == nil {
+			if lang, ok := i18n.Parse(saved); ok {
+				return i18n.Get(lang)
+			}
+		}
+	}
+
+	preferred, _, _ := strings.Cut(r.Header.Get("Accept-Language"), ",")
+	preferred, _, _ = strings.Cut(preferred, ";")
+	return i18n.Get(i18n.Match(preferred))
+}
+
+func renderAuthPage(w http.ResponseWriter, status int, kind authPageKind, m *i18n.Messages) {
+	page := authPage{
+		Lang:      m.Lang,
+		Icon:      "❌",
+		BotURL:    config.Get().BotURL,
+		BackToBot: m.BackToBot,
+	}
+
+	switch kind {
+	case authPageSuccess:
+		page.Icon = "✅"
+		page.Text = m.AuthPageSuccess
+	case authPageDenied:
+		page.Text = m.AuthPageDenied
+	case authPageInvalidState:
+		page.Text = m.AuthPageInvalidState
+	default:
+		page.Text = m.AuthPageFail
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := authPageTemplate.Execute(w, page); err != nil {
+		log.Printf("Failed to render auth page: %v", err)
+	}
+}
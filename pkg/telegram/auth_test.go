@@ -0,0 +1,57 @@
+package telegram
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shakareem/gigoseek/pkg/storage"
+)
+
+// TestAuthEntryPoints каждый способ начать авторизацию должен перевести чат в ожидание её результата
+func TestAuthEntryPoints(t *testing.T) {
+	const chatID int64 = 42
+
+	tests := map[string]func(b *Bot, ctx context.Context) error{
+		"auth command": func(b *Bot, ctx context.Context) error {
+			cmd, _ := lookupCommand(authCommand)
+			return cmd.handle(b, ctx, chatID, "")
+		},
+		"retry button": func(b *Bot, ctx context.Context) error {
+			return b.handleCallback(ctx, &tgbotapi.CallbackQuery{
+				ID:      "callback",
+				Data:    retryAuthCallback,
+				Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: chatID}},
+			})
+		},
+		"reauth prompt": func(b *Bot, ctx context.Context) error {
+			return b.promptReauth(ctx, chatID)
+		},
+	}
+
+	for name, start := range tests {
+		t.Run(name, func(t *testing.T) {
+			tg := newFakeTelegram(t)
+			s := storage.NewInMemoryStorage()
+			b := NewBot(tg.botAPI(t), s, nil, nil)
+
+			if err := start(b, t.Context()); err != nil {
+				t.Fatal(err)
+			}
+
+			conv, err := s.GetConversation(t.Context(), chatID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if conv.State != StateWaitingForAuth {
+				t.Errorf("chat state = %q, want %q", conv.State, StateWaitingForAuth)
+			}
+
+			sent := tg.callsOf("sendMessage")
+			if len(sent) == 0 || !strings.Contains(sent[len(sent)-1].params.Get("text"), "code_challenge") {
+				t.Error("auth link wasn't sent")
+			}
+		})
+	}
+}
@@ -1,142 +1,372 @@
 package telegram
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/shakareem/gigoseek/pkg/concerts"
 	"github.com/shakareem/gigoseek/pkg/config"
 	"github.com/shakareem/gigoseek/pkg/storage"
-	"golang.org/x/oauth2"
 )
 
-type Storage interface {
-	SaveAuthState(state string, chatID int64) error
-	GetChatIDbyAuthState(state string) (int64, error)
-	DeleteAuthState(state string) error
-
-	SaveToken(chatID int64, token oauth2.Token) error
-	GetToken(chatID int64) (oauth2.Token, error)
-	DeleteToken(chatID int64) error
-
-	SaveCity(chatID int64, city string) error
-	GetCity(chatID int64) (string, error)
-	DeleteCity(chatID int64) error
-
-	SaveChatState(chatID int64, state storage.ChatState) error
-	GetChatState(chatID int64) (storage.ChatState, error)
-	DeleteChatState(chatID int64) error
-}
+// Storage объявлен в пакете storage: WithTx передаёт в транзакцию то же хранилище
+type Storage = storage.Storage
 
 type ConcertsProvider interface {
-	GetConcerts(artists []string, city string) []concerts.Concert
+	GetConcerts(ctx context.Context, artists []string, city string) ([]concerts.Concert, error)
+	GetConcert(ctx context.Context, id int) (concerts.Concert, error)
 }
 
 type Bot struct {
 	botAPI           *tgbotapi.BotAPI
 	storage          Storage
 	concertsProvider ConcertsProvider
-	authUpdates      <-chan int64
+	authUpdates      <-chan AuthResult
+	// webhookUpdates без буфера: вебхук отвечает телеграму 200, только когда Start забрал обновление
+	webhookUpdates chan tgbotapi.Update
+	// webhookStopped закрывается, когда Start перестаёт читать webhookUpdates
+	webhookStopped chan struct{}
+	updatesMode    string
+	dispatcher     *dispatcher
+	sender         *sender
 }
 
-func NewBot(botAPI *tgbotapi.BotAPI, storage Storage, concertsProvider ConcertsProvider, authUpdates <-chan int64) *Bot {
+func NewBot(botAPI *tgbotapi.BotAPI, storage Storage, concertsProvider ConcertsProvider, authUpdates <-chan AuthResult) *Bot {
 	return &Bot{
 		botAPI:           botAPI,
 		storage:          storage,
 		concertsProvider: concertsProvider,
 		authUpdates:      authUpdates,
+		webhookUpdates:   make(chan tgbotapi.Update),
+		webhookStopped:   make(chan struct{}),
+		updatesMode:      config.Get().Telegram.UpdatesMode,
+		dispatcher:       newDispatcher(workersCount()),
+		sender:           newSender(botAPI),
+	}
+}
+
+func workersCount() int {
+	if workers := config.Get().Telegram.Workers; workers > 0 {
+		return workers
 	}
+	return defaultWorkers
 }
 
 const (
-	StateIdle storage.ChatState = iota
-	StateWaitingForCity
-	StateWaitingForAuth
+	// shutdownTimeout сколько ждать завершения начатых обработчиков при остановке
+	shutdownTimeout = 10 * time.Second
+	// canceledHandlersTimeout сколько ещё ждать обработчики после отмены их контекста
+	canceledHandlersTimeout = 5 * time.Second
+
+	defaultWorkers        = 16
+	dispatcherLogInterval = time.Minute
 )
 
-func (b *Bot) sendMessage(chatID int64, text string) error {
+func (b *Bot) sendMessage(ctx context.Context, chatID int64, text string) error {
 	msg := tgbotapi.NewMessage(chatID, text)
-	_, err := b.botAPI.Send(msg)
+	_, err := b.sender.Send(ctx, chatID, msg)
 	return err
 }
 
-func (b *Bot) Start() {
+// Start обрабатывает обновления, пока не отменён ctx, после чего дожидается начатых обработчиков
+func (b *Bot) Start(ctx context.Context) error {
 	b.botAPI.Debug = true
 
 	log.Printf("Authorized bot on account %s", b.botAPI.Self.UserName)
 
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
+	// без меню команды всё равно работают, поэтому ошибка не останавливает бота
+	if err := b.publishCommands(); err != nil {
+		log.Println(err)
+	}
+
+	chatUpdates, err := b.receiveUpdates()
+	if err != nil {
+		return err
+	}
+
+	// обработчики не прерываются сразу по сигналу, а получают shutdownTimeout на завершение
+	handlersCtx, cancelHandlers := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelHandlers()
+
+	b.dispatcher.publish("dispatcher")
 
-	chatUpdates := b.botAPI.GetUpdatesChan(u)
+	var background sync.WaitGroup
+	background.Add(3)
+	go func() {
+		defer background.Done()
+		b.runReminderDispatcher(ctx)
+	}()
+	go func() {
+		defer background.Done()
+		b.runTokenRefresher(ctx)
+	}()
+	go func() {
+		defer background.Done()
+		b.logDispatcherStats(ctx)
+	}()
 
 	for {
 		select {
-		case update := <-chatUpdates:
-			go func() {
-				err := b.handleUpdate(update)
-				if err != nil {
-					log.Printf("Error handling message: %v", err)
+		case <-ctx.Done():
+			log.Println("Stopping bot")
+			close(b.webhookStopped)
+			b.stopReceivingUpdates()
+			b.waitHandlers(cancelHandlers)
+			background.Wait()
+			return nil
+		case update, ok := <-chatUpdates:
+			if !ok {
+				chatUpdates = nil
+				continue
+			}
+			chat := update.FromChat()
+			if chat == nil {
+				continue
+			}
+			dispatched := b.dispatcher.Dispatch(chat.ID, func() {
+				ctx := withCorrelationID(handlersCtx, newCorrelationID())
+				ctx = b.withChatLanguage(ctx, chat.ID, languageCode(update.SentFrom()))
+				if err := b.handleUpdate(ctx, update); err != nil {
+					b.reportError(ctx, chat.ID, err)
 				}
-			}()
-		case chatID := <-b.authUpdates:
-			go func() {
-				err := b.handleAuthSuccess(chatID)
-				if err != nil {
-					log.Printf("Error handling auth success: %v", err)
+				// после обработчика: /start к этому моменту уже завёл чат
+				if err := b.storage.SaveChatActivity(ctx, chat.ID, time.Now()); err != nil {
+					log.Printf("Failed to save activity of chat %d: %v", chat.ID, err)
 				}
-			}()
+			})
+			if !dispatched {
+				log.Printf("Dropped update %d: chat %d has %d queued updates", update.UpdateID, chat.ID, maxChatQueue)
+			}
+		case result := <-b.authUpdates:
+			dispatched := b.dispatcher.Dispatch(result.ChatID, func() {
+				ctx := withCorrelationID(handlersCtx, newCorrelationID())
+				ctx = b.withChatLanguage(ctx, result.ChatID, "")
+				if err := b.handleAuthResult(ctx, result); err != nil {
+					b.reportError(ctx, result.ChatID, err)
+				}
+			})
+			if !dispatched {
+				log.Printf("Dropped auth result for chat %d: too many queued updates", result.ChatID)
+			}
 		}
 	}
 }
 
-func (b *Bot) handleUpdate(update tgbotapi.Update) error {
+func (b *Bot) logDispatcherStats(ctx context.Context) {
+	ticker := time.NewTicker(dispatcherLogInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if stats := b.dispatcher.Stats(); stats.Queued > 0 {
+				log.Printf("Dispatcher: %d queued updates in %d chats, %d dropped, %d/%d workers busy",
+					stats.Queued, stats.ActiveChats, stats.Dropped, stats.Busy, stats.Workers)
+			}
+		}
+	}
+}
+
+func (b *Bot) waitHandlers(cancel context.CancelFunc) {
+	done := make(chan struct{})
+	go func() {
+		b.dispatcher.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("All handlers finished")
+		return
+	case <-time.After(shutdownTimeout):
+		log.Printf("Handlers didn't finish in %v, canceling them", shutdownTimeout)
+		cancel()
+	}
+
+	// после отмены обработчики быстро выходят, а хранилище закрывается только после них
+	select {
+	case <-done:
+		log.Println("Canceled handlers finished")
+	case <-time.After(canceledHandlersTimeout):
+		log.Printf("Handlers didn't stop %v after cancellation", canceledHandlersTimeout)
+	}
+}
+
+// stopReceivingUpdates снимает вебхук или останавливает long polling
+func (b *Bot) stopReceivingUpdates() {
+	switch b.updatesMode {
+	case UpdatesModeWebhook:
+		if err := b.deleteWebhook(); err != nil {
+			log.Println(err)
+		}
+	default:
+		b.botAPI.StopReceivingUpdates()
+	}
+}
+
+func (b *Bot) receiveUpdates() (tgbotapi.UpdatesChannel, error) {
+	switch b.updatesMode {
+	case UpdatesModeWebhook:
+		if err := b.setWebhook(); err != nil {
+			return nil, err
+		}
+		log.Println("Receiving updates via webhook")
+		return b.webhookUpdates, nil
+	case UpdatesModePolling, "":
+		// getUpdates не работает, пока у бота установлен вебхук
+		if err := b.deleteWebhook(); err != nil {
+			return nil, err
+		}
+
+		u := tgbotapi.NewUpdate(0)
+		u.Timeout = 60
+		u.AllowedUpdates = allowedUpdates
+
+		log.Println("Receiving updates via long polling")
+		return b.botAPI.GetUpdatesChan(u), nil
+	default:
+		return nil, fmt.Errorf("unknown updates mode %q", b.updatesMode)
+	}
+}
+
+func (b *Bot) handleUpdate(ctx context.Context, update tgbotapi.Update) error {
+	if update.CallbackQuery != nil {
+		return b.handleCallback(ctx, update.CallbackQuery)
+	}
+
 	if update.Message == nil {
 		return nil
 	}
+	msg := update.Message
 
-	chatState, err := b.storage.GetChatState(update.Message.Chat.ID)
+	conv, expired, err := b.conversation(ctx, msg.Chat.ID)
 	if err != nil {
-		chatState = StateIdle
-		b.storage.SaveChatState(update.Message.Chat.ID, StateIdle)
+		return err
+	}
+
+	// команды работают в любом состоянии и не сбрасывают ожидание ввода, кроме /cancel
+	if msg.IsCommand() {
+		return b.handleMessage(ctx, msg)
+	}
+
+	if expired {
+		return b.sendMessage(ctx, msg.Chat.ID, messages(ctx).InputExpired)
+	}
+
+	if onText := conversationStates[conv.State].onText; onText != nil {
+		return onText(b, ctx, msg.Chat.ID, conv, msg.Text)
 	}
 
-	switch chatState {
-	case StateWaitingForAuth:
-		return b.handleAuth(update.Message.Chat.ID)
-	case StateWaitingForCity:
-		return b.handleCityMessage(update.Message.Chat.ID, update.Message.Text)
+	return b.handleMessage(ctx, msg)
+}
+
+func (b *Bot) handleAuthResult(ctx context.Context, result AuthResult) error {
+	if result.Success {
+		return b.handleAuthSuccess(ctx, result.ChatID)
+	}
+	return b.handleAuthFail(ctx, result.ChatID, result.Denied)
+}
+
+func (b *Bot) handleAuthFail(ctx context.Context, chatID int64, denied bool) error {
+	log.Printf("Chat %d failed to auth, denied by user: %v", chatID, denied)
+
+	text := messages(ctx).AuthFail
+	if denied {
+		text = messages(ctx).AuthDenied
 	}
 
-	return b.handleMessage(update.Message)
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(messages(ctx).AuthRetry, retryAuthCallback),
+		),
+	)
+	_, err := b.sender.Send(ctx, chatID, msg)
+	return err
 }
 
-func (b *Bot) handleAuthSuccess(chatID int64) error {
+func (b *Bot) handleAuthSuccess(ctx context.Context, chatID int64) error {
 	log.Printf("Chat %d authed successfully", chatID)
-	b.storage.SaveChatState(chatID, StateIdle)
-	err := b.sendMessage(chatID, config.Get().Messages.AuthSuccess)
+
+	var then pendingCommand
+	conv, err := b.storage.GetConversation(ctx, chatID)
+	if err == nil && conv.State == StateWaitingForAuth {
+		then = decodePendingCommand(chatID, conv)
+	}
+
+	if err := b.transition(ctx, chatID, StateIdle, nil); err != nil {
+		return err
+	}
+	err = b.sendMessage(ctx, chatID, messages(ctx).AuthSuccess)
 	if err != nil {
 		return err
 	}
 
-	if !b.isCitySet(chatID) {
-		return b.handleSetCity(chatID)
+	// команда сама попросит город, если он ей нужен
+	if cmd, ok := lookupCommand(then.Then); ok {
+		return cmd.run(b, ctx, chatID, "")
+	}
+
+	if !b.isCitySet(ctx, chatID) {
+		return b.handleSetCity(ctx, chatID, "")
 	}
 
 	return nil
 }
 
-func (b *Bot) handleCityMessage(chatID int64, city string) error {
-	// TODO: проверять валидность города (мб через timepad)
+// pendingCommand данные состояний ожидания ввода
+type pendingCommand struct {
+	// Then команда, которая ждала ввода и выполнится после него
+	Then string `json:"then,omitempty"`
+}
+
+func decodePendingCommand(chatID int64, conv storage.Conversation) pendingCommand {
+	var pending pendingCommand
+	if len(conv.Payload) > 0 {
+		if err := json.Unmarshal(conv.Payload, &pending); err != nil {
+			log.Printf("Chat %d has malformed %s payload: %v", chatID, conv.State, err)
+		}
+	}
+	return pending
+}
 
-	err := b.storage.SaveCity(chatID, city)
+func (b *Bot) handleCityText(ctx context.Context, chatID int64, conv storage.Conversation, city string) error {
+	input := decodePendingCommand(chatID, conv)
+
+	// город ищется в справочнике по названию и альтернативным написаниям
+	err := b.storage.WithTx(ctx, func(tx Storage) error {
+		if err := tx.SaveCity(ctx, chatID, city); err != nil {
+			return err
+		}
+		return transition(ctx, tx, chatID, StateIdle, nil)
+	})
+	if errors.Is(err, storage.ErrCityNotFound) {
+		return newUserError(messages(ctx).CityNotFound, err)
+	}
 	if err != nil {
-		return b.sendMessage(chatID, fmt.Sprintf("%v", err))
+		return fmt.Errorf("failed to save city for chat %d: %w", chatID, err)
 	}
 	log.Printf("City for chat %d set successfully", chatID)
 
-	b.storage.SaveChatState(chatID, StateIdle)
+	if err := b.sendMessage(ctx, chatID, messages(ctx).CitySuccess); err != nil {
+		return err
+	}
+
+	if cmd, ok := lookupCommand(input.Then); ok {
+		return cmd.run(b, ctx, chatID, "")
+	}
+	return nil
+}
 
-	return b.sendMessage(chatID, messages.CitySuccess)
+// handleAuthText пока чат не авторизован, на любой текст бот повторяет ссылку для авторизации
+func (b *Bot) handleAuthText(ctx context.Context, chatID int64, conv storage.Conversation, text string) error {
+	return b.sendAuthLink(ctx, chatID)
 }
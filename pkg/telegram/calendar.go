@@ -0,0 +1,165 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shakareem/gigoseek/pkg/concerts"
+	"github.com/shakareem/gigoseek/pkg/config"
+	"github.com/shakareem/gigoseek/pkg/i18n"
+)
+
+const (
+	calendarPath          = "/calendar/"
+	calendarFileExtension = ".ics"
+	calendarName          = "gigoseek"
+
+	addToCalendarCallback = "ics"
+	retryAuthCallback     = "auth"
+	callbackSeparator     = ":"
+)
+
+func concertKeyboard(m *i18n.Messages, c concerts.Concert) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(
+				m.AddToCalendar,
+				addToCalendarCallback+callbackSeparator+strconv.Itoa(c.ID),
+			),
+			tgbotapi.NewInlineKeyboardButtonData(
+				m.RemindMe,
+				remindCallback+callbackSeparator+strconv.Itoa(c.ID),
+			),
+		),
+	)
+}
+
+func (b *Bot) handleCallback(ctx context.Context, query *tgbotapi.CallbackQuery) error {
+	// телеграм ждёт ответа на каждый callback, иначе кнопка "зависает"
+	if _, err := b.sender.Request(ctx, tgbotapi.NewCallback(query.ID, "")); err != nil {
+		log.Printf("Failed to answer callback %s: %v", query.ID, err)
+	}
+
+	if query.Message == nil {
+		return nil
+	}
+	chatID := query.Message.Chat.ID
+
+	action, arg, _ := strings.Cut(query.Data, callbackSeparator)
+
+	switch action {
+	case retryAuthCallback:
+		return b.requestAuth(ctx, chatID, "")
+	case deleteMeCallback:
+		return b.handleDeleteMeConfirmed(ctx, chatID)
+	case languageCallback:
+		return b.setLanguage(ctx, chatID, arg)
+	case addToCalendarCallback, remindCallback, cancelReminderCallback:
+		concertID, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("invalid concert id %q in callback: %w", arg, err)
+		}
+		return b.handleConcertCallback(ctx, chatID, action, concertID)
+	default:
+		return fmt.Errorf("unknown callback %q from chat %d", query.Data, chatID)
+	}
+}
+
+func (b *Bot) handleConcertCallback(ctx context.Context, chatID int64, action string, concertID int) error {
+	switch action {
+	case addToCalendarCallback:
+		return b.handleAddToCalendar(ctx, chatID, concertID)
+	case remindCallback:
+		return b.handleRemind(ctx, chatID, concertID)
+	default:
+		return b.handleCancelReminder(ctx, chatID, concertID)
+	}
+}
+
+func (b *Bot) handleAddToCalendar(ctx context.Context, chatID int64, concertID int) error {
+	concert, err := b.concertsProvider.GetConcert(ctx, concertID)
+	if err != nil {
+		return fmt.Errorf("failed to get concert %d: %w", concertID, err)
+	}
+
+	file := tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("concert-%d%s", concert.ID, calendarFileExtension),
+		Bytes: concerts.ICalendar(concert.Name, []concerts.Concert{concert}),
+	}
+
+	doc := tgbotapi.NewDocument(chatID, file)
+	doc.Caption = concert.Name
+	_, err = b.sender.Send(ctx, chatID, doc)
+	return err
+}
+
+func (b *Bot) handleCalendar(ctx context.Context, chatID int64) error {
+	token, err := b.storage.GetCalendarToken(ctx, chatID)
+	if err != nil {
+		token = generateState()
+		if err := b.storage.SaveCalendarToken(ctx, chatID, token); err != nil {
+			return fmt.Errorf("failed to save calendar token for chat %d: %w", chatID, err)
+		}
+		log.Printf("Generated calendar feed for chat %d", chatID)
+	}
+
+	return b.sendMessage(ctx, chatID, messages(ctx).CalendarFeed+calendarFeedURL(token))
+}
+
+func calendarFeedURL(token string) string {
+	return config.Get().CalendarURL + token + calendarFileExtension
+}
+
+func (s *AuthServer) serveCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	token := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, calendarPath), calendarFileExtension)
+
+	chatID, err := s.storage.GetChatIDbyCalendarToken(ctx, token)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	city, err := s.storage.GetCity(ctx, chatID)
+	if err != nil {
+		log.Printf("Calendar feed for chat %d requested without city: %v", chatID, err)
+		http.Error(w, "City is not set", http.StatusNotFound)
+		return
+	}
+
+	artists, err := favoriteArtistsNames(ctx, s.storage, chatID)
+	if err != nil {
+		log.Printf("Failed to get favorite artists for calendar feed of chat %d: %v", chatID, err)
+		http.Error(w, "Couldn't get favorite artists", http.StatusBadGateway)
+		return
+	}
+
+	found, err := s.concertsProvider.GetConcerts(ctx, artists, city.TimepadName)
+	if err != nil {
+		// пустой календарь удалил бы у подписчика все концерты, пусть приложение повторит запрос позже
+		log.Printf("Failed to get concerts for calendar feed of chat %d: %v", chatID, err)
+		http.Error(w, "Couldn't get concerts", http.StatusBadGateway)
+		return
+	}
+
+	upcoming := []concerts.Concert{}
+	now := time.Now()
+	for _, c := range found {
+		startsAt, err := c.StartTime()
+		if err != nil || startsAt.Before(now) {
+			continue
+		}
+		upcoming = append(upcoming, c)
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	if _, err := w.Write(concerts.ICalendar(calendarName, upcoming)); err != nil {
+		log.Printf("Failed to write calendar feed for chat %d: %v", chatID, err)
+	}
+}
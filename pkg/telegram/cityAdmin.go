@@ -0,0 +1,200 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shakareem/gigoseek/pkg/config"
+	"github.com/shakareem/gigoseek/pkg/i18n"
+	"github.com/shakareem/gigoseek/pkg/storage"
+)
+
+const (
+	addCityCommand  = "addcity"
+	editCityCommand = "editcity"
+
+	cityFieldSeparator = "|"
+	defaultTimezone    = "Europe/Moscow"
+)
+
+func isAdmin(chatID int64) bool {
+	return slices.Contains(config.Get().AdminChatIDs, chatID)
+}
+
+// handleAddCity добавляет город в справочник:
+// /addcity Название | timepad=... | lat=... | lon=... | tz=... | population=... | aliases=a,b
+func (b *Bot) handleAddCity(ctx context.Context, chatID int64, args string) error {
+	name, fields, err := parseCityCommand(messages(ctx), args)
+	if err != nil {
+		return cityAdminError(ctx, err)
+	}
+
+	city := storage.City{Name: name, TimepadName: name, Timezone: defaultTimezone}
+	if err := applyCityFields(messages(ctx), &city, fields); err != nil {
+		return cityAdminError(ctx, err)
+	}
+	if err := b.checkCityNamesFree(ctx, city); err != nil {
+		return err
+	}
+
+	city, err = b.storage.AddCity(ctx, city)
+	if err != nil {
+		return fmt.Errorf("failed to add city %q: %w", city.Name, err)
+	}
+
+	log.Printf("Admin %d added city %q", chatID, city.Name)
+	return b.sendMessage(ctx, chatID, messages(ctx).CityAdded+formatCity(messages(ctx), city))
+}
+
+// handleEditCity меняет переданные поля города, без полей показывает город целиком
+func (b *Bot) handleEditCity(ctx context.Context, chatID int64, args string) error {
+	name, fields, err := parseCityCommand(messages(ctx), args)
+	if err != nil {
+		return cityAdminError(ctx, err)
+	}
+
+	city, err := b.storage.FindCity(ctx, name)
+	if errors.Is(err, storage.ErrCityNotFound) {
+		return b.sendMessage(ctx, chatID, messages(ctx).CityNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find city %q: %w", name, err)
+	}
+
+	if len(fields) == 0 {
+		return b.sendMessage(ctx, chatID, formatCity(messages(ctx), city))
+	}
+
+	if err := applyCityFields(messages(ctx), &city, fields); err != nil {
+		return cityAdminError(ctx, err)
+	}
+	if err := b.checkCityNamesFree(ctx, city); err != nil {
+		return err
+	}
+
+	if err := b.storage.UpdateCity(ctx, city); err != nil {
+		return fmt.Errorf("failed to update city %q: %w", city.Name, err)
+	}
+
+	log.Printf("Admin %d updated city %q", chatID, city.Name)
+	return b.sendMessage(ctx, chatID, messages(ctx).CityUpdated+formatCity(messages(ctx), city))
+}
+
+// checkCityNamesFree проверяет, что название и синонимы не заняты другим городом:
+// иначе сохранение упадёт на уникальности, а админ увидит только внутреннюю ошибку
+func (b *Bot) checkCityNamesFree(ctx context.Context, city storage.City) error {
+	for i, name := range append([]string{city.Name}, city.Aliases...) {
+		other, err := b.storage.FindCity(ctx, name)
+		if errors.Is(err, storage.ErrCityNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to find city %q: %w", name, err)
+		}
+		if other.ID == city.ID {
+			continue
+		}
+
+		if i == 0 {
+			return cityAdminError(ctx, fmt.Errorf(messages(ctx).CityExists, name, editCityCommand))
+		}
+		return cityAdminError(ctx, fmt.Errorf(messages(ctx).CityAliasExists, name, other.Name))
+	}
+
+	return nil
+}
+
+// cityAdminError ошибка в аргументах админской команды, админ увидит её вместе с подсказкой
+func cityAdminError(ctx context.Context, err error) error {
+	return newUserError(fmt.Sprintf("⚠️ %v\n\n%s", err, messages(ctx).CityAdminUsage), err)
+}
+
+// parseCityCommand разбирает "Название | ключ=значение | ..." на название и поля
+func parseCityCommand(m *i18n.Messages, args string) (string, map[string]string, error) {
+	parts := strings.Split(args, cityFieldSeparator)
+
+	name := strings.TrimSpace(parts[0])
+	if name == "" {
+		return "", nil, errors.New(m.CityNameRequired)
+	}
+
+	fields := map[string]string{}
+	for _, part := range parts[1:] {
+		key, value, ok := strings.Cut(part, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !ok || key == "" {
+			return "", nil, fmt.Errorf(m.CityFieldFormat, strings.TrimSpace(part))
+		}
+		fields[key] = strings.TrimSpace(value)
+	}
+
+	return name, fields, nil
+}
+
+func applyCityFields(m *i18n.Messages, city *storage.City, fields map[string]string) error {
+	for key, value := range fields {
+		switch key {
+		case "name":
+			if value == "" {
+				return errors.New(m.CityNameEmpty)
+			}
+			city.Name = value
+		case "timepad":
+			if value == "" {
+				return errors.New(m.CityTimepadEmpty)
+			}
+			city.TimepadName = value
+		case "lat":
+			lat, err := strconv.ParseFloat(value, 64)
+			if err != nil || lat < -90 || lat > 90 {
+				return fmt.Errorf(m.CityBadLatitude, value)
+			}
+			city.Latitude = lat
+		case "lon":
+			lon, err := strconv.ParseFloat(value, 64)
+			if err != nil || lon < -180 || lon > 180 {
+				return fmt.Errorf(m.CityBadLongitude, value)
+			}
+			city.Longitude = lon
+		case "tz":
+			if _, err := time.LoadLocation(value); err != nil || value == "" {
+				return fmt.Errorf(m.CityBadTimezone, value)
+			}
+			city.Timezone = value
+		case "population":
+			population, err := strconv.Atoi(value)
+			if err != nil || population < 0 {
+				return fmt.Errorf(m.CityBadPopulation, value)
+			}
+			city.Population = population
+		case "aliases":
+			city.Aliases = nil
+			for _, alias := range strings.Split(value, ",") {
+				if alias = strings.TrimSpace(alias); alias != "" {
+					city.Aliases = append(city.Aliases, alias)
+				}
+			}
+		default:
+			return fmt.Errorf(m.CityUnknownField, key)
+		}
+	}
+
+	return nil
+}
+
+func formatCity(m *i18n.Messages, city storage.City) string {
+	return fmt.Sprintf(m.CityCard,
+		city.Name,
+		city.TimepadName,
+		city.Latitude,
+		city.Longitude,
+		city.Timezone,
+		city.Population,
+		strings.Join(city.Aliases, ", "))
+}
@@ -0,0 +1,222 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shakareem/gigoseek/pkg/config"
+	"github.com/shakareem/gigoseek/pkg/i18n"
+)
+
+// precondition что должно быть у чата, чтобы команда выполнилась
+type precondition int
+
+const (
+	// requiresAuth без рабочего токена Spotify бот сначала просит авторизоваться
+	requiresAuth precondition = 1 << iota
+	// requiresCity без города бот просит его ввести и выполняет команду после выбора
+	requiresCity
+	// requiresAdmin команда доступна только чатам из admin_chat_ids, остальным она не видна
+	requiresAdmin
+)
+
+// commandHandler выполняет команду, args - текст после команды
+type commandHandler func(b *Bot, ctx context.Context, chatID int64, args string) error
+
+// commandArg аргумент команды, нужен для подсказки и проверки обязательных аргументов
+type commandArg struct {
+	// name ключ названия аргумента в CommandArgs каталога
+	name     string
+	required bool
+}
+
+// command описание команды берётся из Commands каталога по её имени
+type command struct {
+	name     string
+	aliases  []string
+	args     []commandArg
+	requires precondition
+	handle   commandHandler
+	// run handle, обёрнутый в commandMiddlewares
+	run commandHandler
+}
+
+// usage строка вида "/addcity <название> [поля]"
+func (c command) usage(m *i18n.Messages) string {
+	var sBuilder strings.Builder
+	sBuilder.WriteString("/" + c.name)
+	for _, arg := range c.args {
+		if arg.required {
+			fmt.Fprintf(&sBuilder, " <%s>", m.CommandArgs[arg.name])
+		} else {
+			fmt.Fprintf(&sBuilder, " [%s]", m.CommandArgs[arg.name])
+		}
+	}
+	return sBuilder.String()
+}
+
+func (c command) missingArgs(args string) bool {
+	if strings.TrimSpace(args) != "" {
+		return false
+	}
+	for _, arg := range c.args {
+		if arg.required {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutArgs адаптирует обработчик команды, которой не нужны аргументы
+func withoutArgs(handle func(b *Bot, ctx context.Context, chatID int64) error) commandHandler {
+	return func(b *Bot, ctx context.Context, chatID int64, _ string) error {
+		return handle(b, ctx, chatID)
+	}
+}
+
+var (
+	// commands в порядке показа в /help и в меню команд
+	commands []command
+	// commandsByName команды по имени и по всем альтернативным написаниям
+	commandsByName map[string]command
+)
+
+func init() {
+	// заполняется в init: /help сам строится по списку команд
+	commands = []command{
+		{name: startCommand, handle: withoutArgs((*Bot).handleStart)},
+		{name: helpCommand, handle: withoutArgs((*Bot).handleHelp)},
+		{name: cancelCommand, handle: withoutArgs((*Bot).handleCancel)},
+		{
+			name:     favouritesCommand,
+			aliases:  []string{"favourites"},
+			requires: requiresAuth,
+			handle:   withoutArgs((*Bot).handleFavouriteArtists),
+		},
+		{
+			name:     concertsCommand,
+			requires: requiresAuth | requiresCity,
+			handle:   withoutArgs((*Bot).handleConcerts),
+		},
+		{
+			name:     calendarCommand,
+			requires: requiresAuth,
+			handle:   withoutArgs((*Bot).handleCalendar),
+		},
+		{name: remindersCommand, handle: withoutArgs((*Bot).handleReminders)},
+		{
+			name: authCommand,
+			handle: func(b *Bot, ctx context.Context, chatID int64, _ string) error {
+				return b.requestAuth(ctx, chatID, "")
+			},
+		},
+		{
+			name:    changeCityCommand,
+			aliases: []string{"changecity"},
+			handle: func(b *Bot, ctx context.Context, chatID int64, _ string) error {
+				return b.handleSetCity(ctx, chatID, "")
+			},
+		},
+		{
+			name:   languageCommand,
+			args:   []commandArg{{name: "language"}},
+			handle: (*Bot).handleLanguage,
+		},
+		{name: logoutCommand, handle: withoutArgs((*Bot).handleLogout)},
+		{name: deleteMeCommand, handle: withoutArgs((*Bot).handleDeleteMe)},
+		{name: exportCommand, handle: withoutArgs((*Bot).handleExport)},
+		{
+			name:     addCityCommand,
+			args:     []commandArg{{name: "city_name", required: true}, {name: "city_fields"}},
+			requires: requiresAdmin,
+			handle:   (*Bot).handleAddCity,
+		},
+		{
+			name:     editCityCommand,
+			args:     []commandArg{{name: "city_name", required: true}, {name: "city_fields"}},
+			requires: requiresAdmin,
+			handle:   (*Bot).handleEditCity,
+		},
+	}
+
+	commandsByName = make(map[string]command)
+	for i := range commands {
+		commands[i].run = chain(commands[i], commands[i].handle)
+		cmd := commands[i]
+		for _, name := range append([]string{cmd.name}, cmd.aliases...) {
+			if _, ok := commandsByName[name]; ok {
+				panic(fmt.Sprintf("command /%s registered twice", name))
+			}
+			commandsByName[name] = cmd
+		}
+	}
+}
+
+// lookupCommand ищет команду по имени или альтернативному написанию
+func lookupCommand(name string) (command, bool) {
+	cmd, ok := commandsByName[strings.ToLower(name)]
+	return cmd, ok
+}
+
+func (b *Bot) handleHelp(ctx context.Context, chatID int64) error {
+	return b.sendTemplate(ctx, chatID, helpTemplate, newHelpData(messages(ctx), isAdmin(chatID)))
+}
+
+// newHelpData список команд для /help, админские команды видны только админам
+func newHelpData(m *i18n.Messages, admin bool) helpData {
+	var data helpData
+	for _, cmd := range commands {
+		if cmd.requires&requiresAdmin != 0 && !admin {
+			continue
+		}
+		data.Commands = append(data.Commands, helpItem{Usage: cmd.usage(m), Description: m.Commands[cmd.name]})
+	}
+	return data
+}
+
+// botCommands список для меню команд Telegram
+func botCommands(m *i18n.Messages, admin bool) []tgbotapi.BotCommand {
+	var botCommands []tgbotapi.BotCommand
+	for _, cmd := range commands {
+		if cmd.requires&requiresAdmin != 0 && !admin {
+			continue
+		}
+		botCommands = append(botCommands, tgbotapi.BotCommand{Command: cmd.name, Description: m.Commands[cmd.name]})
+	}
+	return botCommands
+}
+
+// publishCommands загружает меню команд в Telegram на каждом языке: всем без админских команд, админам - полное.
+// Меню без языка показывается тем, чей язык не поддерживается
+func (b *Bot) publishCommands() error {
+	type commandMenu struct {
+		scope tgbotapi.BotCommandScope
+		admin bool
+	}
+
+	menus := []commandMenu{{scope: tgbotapi.NewBotCommandScopeDefault()}}
+	for _, chatID := range config.Get().AdminChatIDs {
+		menus = append(menus, commandMenu{scope: tgbotapi.NewBotCommandScopeChat(chatID), admin: true})
+	}
+
+	for _, menu := range menus {
+		fallback := tgbotapi.NewSetMyCommandsWithScope(menu.scope, botCommands(i18n.Get(i18n.English), menu.admin)...)
+		if _, err := b.botAPI.Request(fallback); err != nil {
+			return fmt.Errorf("failed to set bot commands for scope %s: %w", menu.scope.Type, err)
+		}
+
+		for _, lang := range i18n.Languages {
+			localized := tgbotapi.NewSetMyCommandsWithScopeAndLanguage(
+				menu.scope, string(lang), botCommands(i18n.Get(lang), menu.admin)...)
+			if _, err := b.botAPI.Request(localized); err != nil {
+				return fmt.Errorf("failed to set %s bot commands for scope %s: %w", lang, menu.scope.Type, err)
+			}
+		}
+	}
+
+	log.Printf("Published %d bot commands in %d languages", len(commands), len(i18n.Languages))
+	return nil
+}
@@ -0,0 +1,31 @@
+package telegram
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/shakareem/gigoseek/pkg/i18n"
+)
+
+// telegram принимает в меню только такие имена команд
+var botCommandName = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+func TestCommands(t *testing.T) {
+	for _, cmd := range commands {
+		if !botCommandName.MatchString(cmd.name) {
+			t.Errorf("command name %q is not accepted by Telegram", cmd.name)
+		}
+
+		for _, lang := range i18n.Languages {
+			m := i18n.Get(lang)
+			if description := m.Commands[cmd.name]; description == "" || len(description) > 256 {
+				t.Errorf("%s description of /%s = %q, want 1-256 characters", lang, cmd.name, description)
+			}
+			for _, arg := range cmd.args {
+				if m.CommandArgs[arg.name] == "" {
+					t.Errorf("%s catalog has no name of /%s argument %q", lang, cmd.name, arg.name)
+				}
+			}
+		}
+	}
+}
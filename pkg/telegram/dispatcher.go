@@ -0,0 +1,134 @@
+package telegram
+
+import (
+	"expvar"
+	"log"
+	"runtime/debug"
+	"sync"
+)
+
+// maxChatQueue сколько задач одного чата может ждать в очереди, остальные отбрасываются,
+// чтобы один чат, засыпающий бота сообщениями, не занимал память без ограничений
+const maxChatQueue = 20
+
+// dispatcher выполняет задачи одного чата строго по очереди, а задачи разных чатов параллельно,
+// но не больше чем в workers горутинах одновременно
+type dispatcher struct {
+	workers chan struct{}
+
+	mu      sync.Mutex
+	queues  map[int64][]func()
+	queued  int
+	active  int
+	dropped int
+
+	wg sync.WaitGroup
+}
+
+type dispatcherStats struct {
+	Queued      int `json:"queued"`
+	Dropped     int `json:"dropped"`
+	ActiveChats int `json:"active_chats"`
+	Busy        int `json:"busy_workers"`
+	Workers     int `json:"workers"`
+}
+
+func newDispatcher(workers int) *dispatcher {
+	return &dispatcher{
+		workers: make(chan struct{}, workers),
+		queues:  make(map[int64][]func()),
+	}
+}
+
+// Dispatch ставит задачу в очередь чата и запускает обработку очереди, если она ещё не запущена.
+// Если в очереди чата уже maxChatQueue задач, новая отбрасывается и Dispatch возвращает false
+func (d *dispatcher) Dispatch(chatID int64, job func()) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	queue, running := d.queues[chatID]
+	if len(queue) >= maxChatQueue {
+		d.dropped++
+		return false
+	}
+	d.queues[chatID] = append(queue, job)
+	d.queued++
+
+	if running {
+		return true
+	}
+
+	d.active++
+	d.wg.Add(1)
+	go d.run(chatID)
+	return true
+}
+
+func (d *dispatcher) run(chatID int64) {
+	defer d.wg.Done()
+
+	for {
+		job, ok := d.next(chatID)
+		if !ok {
+			return
+		}
+
+		// слот берётся на каждую задачу, чтобы один активный чат не занимал воркер надолго
+		d.runJob(chatID, job)
+	}
+}
+
+// runJob выполняет задачу в слоте воркера. Паника в задаче не роняет бота и не останавливает очередь чата
+func (d *dispatcher) runJob(chatID int64, job func()) {
+	d.workers <- struct{}{}
+	defer func() {
+		<-d.workers
+		if r := recover(); r != nil {
+			log.Printf("Panic while handling update for chat %d: %v\n%s", chatID, r, debug.Stack())
+		}
+	}()
+
+	job()
+}
+
+func (d *dispatcher) next(chatID int64) (func(), bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	queue := d.queues[chatID]
+	if len(queue) == 0 {
+		delete(d.queues, chatID)
+		d.active--
+		return nil, false
+	}
+
+	job := queue[0]
+	// иначе выполненная задача и всё, что она захватила, живут в массиве, пока очередь чата не опустеет
+	queue[0] = nil
+	d.queues[chatID] = queue[1:]
+	d.queued--
+	return job, true
+}
+
+// Wait дожидается выполнения всех поставленных задач
+func (d *dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+func (d *dispatcher) Stats() dispatcherStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return dispatcherStats{
+		Queued:      d.queued,
+		Dropped:     d.dropped,
+		ActiveChats: d.active,
+		Busy:        len(d.workers),
+		Workers:     cap(d.workers),
+	}
+}
+
+// publish делает статистику доступной через expvar, она отдаётся на /debug/vars отладочного сервера
+func (d *dispatcher) publish(name string) {
+	expvar.Publish(name, expvar.Func(func() any { return d.Stats() }))
+}
@@ -0,0 +1,103 @@
+package telegram
+
+import (
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestDispatcherKeepsChatOrder(t *testing.T) {
+	d := newDispatcher(4)
+
+	var mu sync.Mutex
+	done := map[int64][]int{}
+	for i := range 10 {
+		for _, chatID := range []int64{1, 2, 3} {
+			d.Dispatch(chatID, func() {
+				mu.Lock()
+				defer mu.Unlock()
+				done[chatID] = append(done[chatID], i)
+			})
+		}
+	}
+	d.Wait()
+
+	want := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	for _, chatID := range []int64{1, 2, 3} {
+		if !slices.Equal(done[chatID], want) {
+			t.Errorf("chat %d jobs ran in order %v, want %v", chatID, done[chatID], want)
+		}
+	}
+	if stats := d.Stats(); stats.Queued != 0 || stats.ActiveChats != 0 {
+		t.Errorf("stats after Wait = %+v, want empty queues", stats)
+	}
+}
+
+func TestDispatcherDropsOverflow(t *testing.T) {
+	d := newDispatcher(1)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	d.Dispatch(1, func() {
+		close(started)
+		<-release
+	})
+	<-started
+
+	var mu sync.Mutex
+	ran := 0
+	for i := range maxChatQueue {
+		if !d.Dispatch(1, func() {
+			mu.Lock()
+			ran++
+			mu.Unlock()
+		}) {
+			t.Fatalf("job %d dropped before the queue is full", i)
+		}
+	}
+	if d.Dispatch(1, func() { t.Error("dropped job ran") }) {
+		t.Error("Dispatch accepted a job over maxChatQueue")
+	}
+	// очередь другого чата не зависит от переполненной
+	otherDone := make(chan struct{})
+	if !d.Dispatch(2, func() { close(otherDone) }) {
+		t.Error("job of another chat dropped")
+	}
+
+	if stats := d.Stats(); stats.Dropped != 1 || stats.Queued != maxChatQueue+1 {
+		t.Errorf("stats = %+v, want 1 dropped and %d queued", stats, maxChatQueue+1)
+	}
+
+	close(release)
+	d.Wait()
+	<-otherDone
+	if ran != maxChatQueue {
+		t.Errorf("%d queued jobs ran, want %d", ran, maxChatQueue)
+	}
+}
+
+func TestDispatcherReleasesDoneJobs(t *testing.T) {
+	d := newDispatcher(1)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	d.Dispatch(1, func() {
+		close(started)
+		<-release
+	})
+	<-started
+	d.Dispatch(1, func() {})
+	d.Dispatch(1, func() {})
+
+	// забираем следующую задачу вручную и смотрим на массив очереди за пределами среза
+	d.mu.Lock()
+	queue := d.queues[1]
+	d.mu.Unlock()
+	if _, ok := d.next(1); !ok {
+		t.Fatal("queue is empty")
+	}
+	if queue[0] != nil {
+		t.Error("taken job is still referenced by the queue")
+	}
+
+	close(release)
+	d.Wait()
+}
@@ -0,0 +1,151 @@
+package telegram
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/shakareem/gigoseek/pkg/concerts"
+	"github.com/zmb3/spotify/v2"
+	"golang.org/x/oauth2"
+)
+
+// errorKind от вида ошибки зависит, что увидит пользователь
+type errorKind int
+
+const (
+	// errorInternal ошибка в боте или хранилище: пользователь получает код, по которому её можно найти в логах
+	errorInternal errorKind = iota
+	// errorRetryable временный сбой Spotify, Timepad или сети: стоит повторить позже
+	errorRetryable
+	// errorUser пользователь ввёл что-то не то, ему показывается текст ошибки
+	errorUser
+	// errorReauth доступ к Spotify отозван, вместо ошибки присылается новая ссылка для авторизации
+	errorReauth
+)
+
+func (k errorKind) String() string {
+	switch k {
+	case errorRetryable:
+		return "retryable"
+	case errorUser:
+		return "user"
+	case errorReauth:
+		return "reauth"
+	default:
+		return "internal"
+	}
+}
+
+// userError ошибка во вводе пользователя, text показывается ему как есть
+type userError struct {
+	text string
+	err  error
+}
+
+func newUserError(text string, err error) error {
+	return &userError{text: text, err: err}
+}
+
+func (e *userError) Error() string {
+	if e.err != nil {
+		return e.err.Error()
+	}
+	return e.text
+}
+
+func (e *userError) Unwrap() error {
+	return e.err
+}
+
+func classifyError(err error) errorKind {
+	var (
+		userErr     *userError
+		spotifyErr  spotify.Error
+		timepadErr  *concerts.StatusError
+		retrieveErr *oauth2.RetrieveError
+		netErr      net.Error
+	)
+
+	switch {
+	case errors.As(err, &userErr):
+		return errorUser
+	case errors.Is(err, errReauthRequired):
+		return errorReauth
+	case errors.As(err, &spotifyErr):
+		return classifyStatus(spotifyErr.Status)
+	case errors.As(err, &timepadErr):
+		return classifyStatus(timepadErr.StatusCode)
+	case errors.As(err, &retrieveErr) && retrieveErr.Response != nil:
+		return classifyStatus(retrieveErr.Response.StatusCode)
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr):
+		return errorRetryable
+	default:
+		return errorInternal
+	}
+}
+
+// classifyStatus ответы 429 и 5xx означают, что сервис перегружен или лежит
+func classifyStatus(code int) errorKind {
+	if code == http.StatusTooManyRequests || code >= http.StatusInternalServerError {
+		return errorRetryable
+	}
+	return errorInternal
+}
+
+type correlationIDKey struct{}
+
+// newCorrelationID короткий код обновления, который видят и пользователь, и логи
+func newCorrelationID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatal(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func withCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+func correlationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	if id == "" {
+		return "-"
+	}
+	return id
+}
+
+// reportError пишет ошибку в лог вместе с кодом обновления и сообщает о ней пользователю
+func (b *Bot) reportError(ctx context.Context, chatID int64, err error) {
+	id := correlationID(ctx)
+	kind := classifyError(err)
+	log.Printf("[%s] Error handling update for chat %d (%s): %v", id, chatID, kind, err)
+
+	// бот останавливается, сообщать уже некуда
+	if ctx.Err() != nil {
+		return
+	}
+
+	var sendErr error
+	switch kind {
+	case errorUser:
+		var userErr *userError
+		errors.As(err, &userErr)
+		sendErr = b.sendMessage(ctx, chatID, userErr.text)
+	case errorReauth:
+		sendErr = b.promptReauth(ctx, chatID)
+	case errorRetryable:
+		sendErr = b.sendMessage(ctx, chatID, fmt.Sprintf(messages(ctx).ErrorRetryable, id))
+	default:
+		sendErr = b.sendMessage(ctx, chatID, fmt.Sprintf(messages(ctx).ErrorInternal, id))
+	}
+	if sendErr != nil {
+		log.Printf("[%s] Failed to report error to chat %d: %v", id, chatID, sendErr)
+	}
+}
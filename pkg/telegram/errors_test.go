@@ -0,0 +1,41 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/shakareem/gigoseek/pkg/concerts"
+	"github.com/shakareem/gigoseek/pkg/storage"
+	"github.com/zmb3/spotify/v2"
+	"golang.org/x/oauth2"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want errorKind
+	}{
+		{"internal", errors.New("boom"), errorInternal},
+		{"user", fmt.Errorf("wrapped: %w", newUserError("нет такого города", storage.ErrCityNotFound)), errorUser},
+		{"reauth", fmt.Errorf("failed to get favorite artists: %w", errReauthRequired), errorReauth},
+		{"timeout", fmt.Errorf("request: %w", context.DeadlineExceeded), errorRetryable},
+		{"spotify outage", spotify.Error{Message: "unavailable", Status: http.StatusServiceUnavailable}, errorRetryable},
+		{"spotify rate limit", spotify.Error{Message: "slow down", Status: http.StatusTooManyRequests}, errorRetryable},
+		{"spotify bad request", spotify.Error{Message: "bad", Status: http.StatusBadRequest}, errorInternal},
+		{"timepad outage", &concerts.StatusError{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway"}, errorRetryable},
+		{"concerts search outage", fmt.Errorf("failed to get concerts: %w", &concerts.StatusError{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable"}), errorRetryable},
+		{"token refresh outage", &oauth2.RetrieveError{Response: &http.Response{StatusCode: http.StatusInternalServerError}}, errorRetryable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyError(tt.err); got != tt.want {
+				t.Errorf("classifyError(%v) = %s, want %s", tt.err, got, tt.want)
+			}
+		})
+	}
+}
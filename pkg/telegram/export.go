@@ -0,0 +1,61 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shakareem/gigoseek/pkg/storage"
+)
+
+const exportFileName = "gigoseek-export.json"
+
+// personalDataExport выгрузка по /export: данные из базы и любимые артисты из spotify
+type personalDataExport struct {
+	ExportedAt      time.Time `json:"exported_at"`
+	FavoriteArtists []string  `json:"favorite_artists"`
+	// FavoriteArtistsUnavailable spotify не ответил, выгрузка сделана без любимых артистов
+	FavoriteArtistsUnavailable bool `json:"favorite_artists_unavailable,omitempty"`
+	storage.ChatExport
+}
+
+func (b *Bot) handleExport(ctx context.Context, chatID int64) error {
+	data, err := b.storage.ExportChat(ctx, chatID)
+	if err != nil {
+		return fmt.Errorf("failed to export data of chat %d: %w", chatID, err)
+	}
+
+	export := personalDataExport{
+		ExportedAt:      time.Now().UTC(),
+		FavoriteArtists: []string{},
+		ChatExport:      data,
+	}
+
+	if b.isAuthorized(ctx, chatID) {
+		// данные из базы выгружаются, даже если spotify недоступен
+		artists, err := favoriteArtistsNames(ctx, b.storage, chatID)
+		if err != nil {
+			log.Printf("Exporting data of chat %d without favorite artists: %v", chatID, err)
+			export.FavoriteArtistsUnavailable = true
+		} else {
+			export.FavoriteArtists = artists
+		}
+	}
+
+	body, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: exportFileName, Bytes: body})
+	doc.Caption = messages(ctx).Export
+	if _, err := b.sender.Send(ctx, chatID, doc); err != nil {
+		return err
+	}
+
+	log.Printf("Exported data of chat %d", chatID)
+	return nil
+}
@@ -0,0 +1,89 @@
+package telegram
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strconv"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fakeTelegram поддельный Bot API: запоминает вызовы и отвечает на них успехом
+type fakeTelegram struct {
+	server *httptest.Server
+
+	mu    sync.Mutex
+	calls []fakeCall
+	// respond может ответить на вызов сам, например ошибкой 429; false - ответить успехом
+	respond func(w http.ResponseWriter, call fakeCall) bool
+}
+
+type fakeCall struct {
+	method string
+	params url.Values
+}
+
+func newFakeTelegram(t *testing.T) *fakeTelegram {
+	f := &fakeTelegram{}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeTelegram) botAPI(t *testing.T) *tgbotapi.BotAPI {
+	t.Helper()
+
+	botAPI, err := tgbotapi.NewBotAPIWithClient("test-token", f.server.URL+"/bot%s/%s", f.server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return botAPI
+}
+
+func (f *fakeTelegram) serve(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	call := fakeCall{method: path.Base(r.URL.Path), params: r.PostForm}
+
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	respond := f.respond
+	f.mu.Unlock()
+
+	if respond != nil && respond(w, call) {
+		return
+	}
+
+	var result any = true
+	switch call.method {
+	case "getMe":
+		result = tgbotapi.User{ID: 1, IsBot: true, FirstName: "test", UserName: "test_bot"}
+	case "sendMessage", "sendDocument":
+		chatID, _ := strconv.ParseInt(call.params.Get("chat_id"), 10, 64)
+		result = tgbotapi.Message{MessageID: len(f.callsOf(call.method)), Chat: &tgbotapi.Chat{ID: chatID}}
+	}
+
+	raw, _ := json.Marshal(result)
+	json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: true, Result: raw})
+}
+
+// callsOf вызовы метода в порядке поступления
+func (f *fakeTelegram) callsOf(method string) []fakeCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var calls []fakeCall
+	for _, call := range f.calls {
+		if call.method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
@@ -0,0 +1,151 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/shakareem/gigoseek/pkg/storage"
+)
+
+// Состояния диалога. Названия хранятся в базе, поэтому их нельзя менять без миграции
+const (
+	StateIdle                             = storage.DefaultChatState
+	StateWaitingForCity storage.ChatState = "waiting_for_city"
+	StateWaitingForAuth storage.ChatState = "waiting_for_auth"
+)
+
+// cityInputTimeout сколько бот ждёт название города
+const cityInputTimeout = 30 * time.Minute
+
+var errInvalidTransition = errors.New("invalid chat state transition")
+
+// textHandler обрабатывает текст, который пользователь прислал в этом состоянии
+type textHandler func(b *Bot, ctx context.Context, chatID int64, conv storage.Conversation, text string) error
+
+// conversationState что бот делает в состоянии и куда из него можно перейти
+type conversationState struct {
+	// onText nil, если состояние не ждёт ввода: текст обрабатывается как в StateIdle
+	onText textHandler
+	// timeout после него состояние сбрасывается в StateIdle, 0 - не истекает
+	timeout time.Duration
+	// next разрешённые переходы. В StateIdle и в то же состояние можно перейти всегда
+	next []storage.ChatState
+}
+
+var conversationStates map[storage.ChatState]conversationState
+
+func init() {
+	// заполняется в init: обработчики сами переводят чат между состояниями
+	conversationStates = map[storage.ChatState]conversationState{
+		StateIdle: {
+			next: []storage.ChatState{StateWaitingForCity, StateWaitingForAuth},
+		},
+		StateWaitingForCity: {
+			onText:  (*Bot).handleCityText,
+			timeout: cityInputTimeout,
+			next:    []storage.ChatState{StateWaitingForAuth},
+		},
+		StateWaitingForAuth: {
+			onText:  (*Bot).handleAuthText,
+			timeout: authStateTTL,
+			next:    []storage.ChatState{StateWaitingForCity},
+		},
+	}
+}
+
+func canTransition(from, to storage.ChatState) bool {
+	return to == StateIdle || to == from || slices.Contains(conversationStates[from].next, to)
+}
+
+// transition переводит чат в состояние to и сохраняет данные состояния
+func (b *Bot) transition(ctx context.Context, chatID int64, to storage.ChatState, payload any) error {
+	return b.storage.WithTx(ctx, func(tx Storage) error {
+		return transition(ctx, tx, chatID, to, payload)
+	})
+}
+
+// transition то же, что Bot.transition, но внутри уже открытой транзакции
+func transition(ctx context.Context, tx Storage, chatID int64, to storage.ChatState, payload any) error {
+	if _, ok := conversationStates[to]; !ok {
+		return fmt.Errorf("unknown chat state %q", to)
+	}
+
+	from := StateIdle
+	conv, err := tx.GetConversation(ctx, chatID)
+	switch {
+	case err == nil:
+		from = conv.State
+	case !errors.Is(err, storage.ErrChatNotFound):
+		return err
+	}
+
+	if !canTransition(from, to) {
+		return fmt.Errorf("%w for chat %d: %s -> %s", errInvalidTransition, chatID, from, to)
+	}
+
+	var raw json.RawMessage
+	if payload != nil {
+		if raw, err = json.Marshal(payload); err != nil {
+			return fmt.Errorf("failed to encode %s payload: %w", to, err)
+		}
+	}
+
+	if err := tx.SaveConversation(ctx, chatID, storage.Conversation{State: to, Payload: raw}); err != nil {
+		return err
+	}
+
+	log.Printf("Chat %d: %s -> %s", chatID, from, to)
+	return nil
+}
+
+// conversation возвращает текущее состояние чата. Новые чаты заводятся в StateIdle,
+// а истёкшие и неизвестные состояния сбрасываются; expired сообщает, что ожидание ввода истекло
+func (b *Bot) conversation(ctx context.Context, chatID int64) (conv storage.Conversation, expired bool, err error) {
+	conv, err = b.storage.GetConversation(ctx, chatID)
+	if errors.Is(err, storage.ErrChatNotFound) {
+		if err := b.storage.SaveChatState(ctx, chatID, StateIdle); err != nil {
+			return storage.Conversation{}, false, fmt.Errorf("failed to register chat %d: %w", chatID, err)
+		}
+		return storage.Conversation{State: StateIdle}, false, nil
+	}
+	if err != nil {
+		return storage.Conversation{}, false, fmt.Errorf("failed to get state of chat %d: %w", chatID, err)
+	}
+
+	spec, known := conversationStates[conv.State]
+	expired = spec.timeout > 0 && time.Since(conv.UpdatedAt) > spec.timeout
+	if known && !expired {
+		return conv, false, nil
+	}
+
+	if !known {
+		log.Printf("Chat %d is in unknown state %q, resetting", chatID, conv.State)
+	} else {
+		log.Printf("Chat %d state %s expired", chatID, conv.State)
+	}
+	if err := b.storage.DeleteChatState(ctx, chatID); err != nil {
+		return storage.Conversation{}, false, fmt.Errorf("failed to reset state of chat %d: %w", chatID, err)
+	}
+	return storage.Conversation{State: StateIdle}, expired, nil
+}
+
+// handleCancel отменяет ожидание ввода в любом состоянии
+func (b *Bot) handleCancel(ctx context.Context, chatID int64) error {
+	conv, err := b.storage.GetConversation(ctx, chatID)
+	if err != nil && !errors.Is(err, storage.ErrChatNotFound) {
+		return fmt.Errorf("failed to get state of chat %d: %w", chatID, err)
+	}
+	if err != nil || conv.State == StateIdle {
+		return b.sendMessage(ctx, chatID, messages(ctx).NothingToCancel)
+	}
+
+	if err := b.transition(ctx, chatID, StateIdle, nil); err != nil {
+		return err
+	}
+	return b.sendMessage(ctx, chatID, messages(ctx).Canceled)
+}
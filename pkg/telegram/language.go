@@ -0,0 +1,97 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shakareem/gigoseek/pkg/i18n"
+	"github.com/shakareem/gigoseek/pkg/storage"
+)
+
+const (
+	languageCommand  = "language"
+	languageCallback = "language"
+)
+
+type languageKey struct{}
+
+func withLanguage(ctx context.Context, lang i18n.Lang) context.Context {
+	return context.WithValue(ctx, languageKey{}, lang)
+}
+
+// messages тексты на языке чата, для которого выполняется обработчик
+func messages(ctx context.Context) *i18n.Messages {
+	lang, _ := ctx.Value(languageKey{}).(i18n.Lang)
+	return i18n.Get(lang)
+}
+
+// withChatLanguage кладёт в ctx язык чата. Пока пользователь не выбрал язык через /language,
+// он определяется по language_code из Telegram и запоминается, чтобы на нём же приходили напоминания
+func (b *Bot) withChatLanguage(ctx context.Context, chatID int64, languageCode string) context.Context {
+	saved, err := b.storage.GetLanguage(ctx, chatID)
+	if err != nil && !errors.Is(err, storage.ErrChatNotFound) {
+		log.Printf("Failed to get language of chat %d: %v", chatID, err)
+	}
+	if lang, ok := i18n.Parse(saved); err == nil && ok {
+		return withLanguage(ctx, lang)
+	}
+
+	lang := i18n.Match(languageCode)
+	// новый чат заводится позже, язык сохранится при следующем обновлении
+	if err == nil && languageCode != "" {
+		if err := b.storage.SaveLanguage(ctx, chatID, string(lang)); err != nil {
+			log.Printf("Failed to save language of chat %d: %v", chatID, err)
+		}
+	}
+	return withLanguage(ctx, lang)
+}
+
+func languageCode(user *tgbotapi.User) string {
+	if user == nil {
+		return ""
+	}
+	return user.LanguageCode
+}
+
+// handleLanguage без аргумента предлагает выбрать язык кнопками
+func (b *Bot) handleLanguage(ctx context.Context, chatID int64, args string) error {
+	if strings.TrimSpace(args) != "" {
+		return b.setLanguage(ctx, chatID, args)
+	}
+
+	var row []tgbotapi.InlineKeyboardButton
+	for _, lang := range i18n.Languages {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+			i18n.Get(lang).LanguageName,
+			languageCallback+callbackSeparator+string(lang),
+		))
+	}
+
+	msg := tgbotapi.NewMessage(chatID, messages(ctx).LanguagePrompt)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(row)
+	_, err := b.sender.Send(ctx, chatID, msg)
+	return err
+}
+
+func (b *Bot) setLanguage(ctx context.Context, chatID int64, code string) error {
+	lang, ok := i18n.Parse(code)
+	if !ok {
+		available := make([]string, len(i18n.Languages))
+		for i, lang := range i18n.Languages {
+			available[i] = string(lang)
+		}
+		return newUserError(fmt.Sprintf(messages(ctx).LanguageUnknown, strings.Join(available, ", ")), nil)
+	}
+
+	if err := b.storage.SaveLanguage(ctx, chatID, string(lang)); err != nil {
+		return fmt.Errorf("failed to save language of chat %d: %w", chatID, err)
+	}
+	log.Printf("Chat %d switched language to %s", chatID, lang)
+
+	ctx = withLanguage(ctx, lang)
+	return b.sendMessage(ctx, chatID, messages(ctx).LanguageSet)
+}
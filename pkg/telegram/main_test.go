@@ -0,0 +1,16 @@
+package telegram
+
+import (
+	"os"
+	"testing"
+
+	"github.com/shakareem/gigoseek/pkg/config"
+)
+
+// TestMain указывает конфиг репозитория: go test запускает тесты из директории пакета
+func TestMain(m *testing.M) {
+	if os.Getenv(config.PathEnv) == "" {
+		os.Setenv(config.PathEnv, "../../configs/config.json")
+	}
+	os.Exit(m.Run())
+}
@@ -4,11 +4,10 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"strings"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
-	"github.com/shakareem/gigoseek/pkg/config"
+	"github.com/shakareem/gigoseek/pkg/storage"
 	"github.com/zmb3/spotify/v2"
 	"golang.org/x/oauth2"
 )
@@ -18,189 +17,188 @@ const (
 	authCommand       = "auth"
 	helpCommand       = "help"
 	favouritesCommand = "favorites"
-	changeCityCommand = "changecity"
+	changeCityCommand = "change_city"
 	concertsCommand   = "concerts"
+	calendarCommand   = "calendar"
+	remindersCommand  = "reminders"
+	logoutCommand     = "logout"
+	deleteMeCommand   = "delete_me"
+	exportCommand     = "export"
+	cancelCommand     = "cancel"
+
+	// maxConcertCards больше карточек за раз не отправляется, чтобы не заваливать чат и не тратить лимит Telegram
+	maxConcertCards = 10
 )
 
 // TODO: мб интерфейсы ArtistsProvider и ConcertProvider
 
-var messages = config.Get().Messages
-
-func (b *Bot) handleMessage(msg *tgbotapi.Message) error {
+func (b *Bot) handleMessage(ctx context.Context, msg *tgbotapi.Message) error {
 	if !msg.IsCommand() {
-		return b.sendMessage(msg.Chat.ID, messages.Help)
-	}
-
-	switch msg.Command() {
-	case startCommand:
-		return b.handleStart(msg.Chat.ID)
-	case authCommand:
-		return b.handleAuth(msg.Chat.ID)
-	case helpCommand:
-		return b.sendMessage(msg.Chat.ID, messages.Help)
-	case favouritesCommand:
-		return b.handleFavouriteArtists(msg.Chat.ID)
-	case changeCityCommand:
-		return b.handleSetCity(msg.Chat.ID)
-	case concertsCommand:
-		return b.handleConcerts(msg.Chat.ID)
-	default:
-		return b.sendMessage(msg.Chat.ID, messages.Help)
+		return b.handleHelp(ctx, msg.Chat.ID)
+	}
+
+	cmd, ok := lookupCommand(msg.Command())
+	if !ok {
+		return b.handleHelp(ctx, msg.Chat.ID)
 	}
+
+	return cmd.run(b, ctx, msg.Chat.ID, msg.CommandArguments())
 }
 
-func (b *Bot) handleStart(chatID int64) error {
-	err := b.sendMessage(chatID, messages.Start)
+func (b *Bot) handleStart(ctx context.Context, chatID int64) error {
+	err := b.sendMessage(ctx, chatID, messages(ctx).Start)
 	if err != nil {
 		return err
 	}
 
-	if !b.isAuthorized(chatID) {
-		b.storage.SaveChatState(chatID, StateWaitingForAuth)
-		return b.handleAuth(chatID)
+	if !b.isAuthorized(ctx, chatID) {
+		return b.requestAuth(ctx, chatID, "")
 	}
 
-	if !b.isCitySet(chatID) {
-		return b.handleSetCity(chatID)
+	if !b.isCitySet(ctx, chatID) {
+		return b.handleSetCity(ctx, chatID, "")
 	}
 
 	return nil
 }
 
-func (b *Bot) isCitySet(chatID int64) bool {
-	_, err := b.storage.GetCity(chatID)
+func (b *Bot) isCitySet(ctx context.Context, chatID int64) bool {
+	_, err := b.storage.GetCity(ctx, chatID)
 	return err == nil
 }
 
-func (b *Bot) isAuthorized(chatID int64) bool {
-	token, err := b.storage.GetToken(chatID)
-	if err != nil {
-		log.Printf("Failed to get token for chat %d: %v", chatID, err)
+// isAuthorized проверяет, что у чата есть рабочий токен, и при необходимости обновляет его
+func (b *Bot) isAuthorized(ctx context.Context, chatID int64) bool {
+	if _, err := newTokenSource(ctx, b.storage, chatID).Token(); err != nil {
+		log.Printf("Chat %d is not authorized: %v", chatID, err)
 		return false
 	}
-
-	if !token.Valid() {
-		err = b.refreshExpiredToken(chatID, &token)
-		if err != nil {
-			log.Println(err)
-			return false
-		}
-		return b.isAuthorized(chatID)
-	}
-
 	return true
 }
 
-func (b *Bot) refreshExpiredToken(chatID int64, token *oauth2.Token) error {
-	newToken, err := auth.RefreshToken(context.Background(), token)
-	if err != nil {
-		return fmt.Errorf("failed to refresh access token for chat %d: %w", chatID, err)
-	}
-	b.storage.DeleteToken(chatID)
-	err = b.storage.SaveToken(chatID, *newToken)
-	if err != nil {
-		return fmt.Errorf("failed to save refreshed token: %w", err)
+// requestAuth переводит чат в ожидание авторизации. then команда, которую нужно выполнить после неё
+func (b *Bot) requestAuth(ctx context.Context, chatID int64, then string) error {
+	if err := b.transition(ctx, chatID, StateWaitingForAuth, pendingCommand{Then: then}); err != nil {
+		return err
 	}
-
-	log.Printf("Token for chat %d refreshed successfully", chatID)
-	return nil
+	return b.sendAuthLink(ctx, chatID)
 }
 
-func (b *Bot) handleAuth(chatID int64) error {
+// sendAuthLink присылает новую ссылку для авторизации, не меняя состояние чата.
+// Начинать авторизацию нужно через requestAuth, чтобы чат ждал её результата
+func (b *Bot) sendAuthLink(ctx context.Context, chatID int64) error {
 	state := generateState() // мб генерировать не тут, а при появлении нового пользователя
-	b.storage.SaveAuthState(state, chatID)
+	verifier := generateCodeVerifier()
+
+	err := b.storage.SaveAuthState(ctx, storage.AuthState{State: state, ChatID: chatID, CodeVerifier: verifier})
+	if err != nil {
+		return fmt.Errorf("failed to save auth state for chat %d: %w", chatID, err)
+	}
 
 	log.Printf("Generated state %v for chat %v", state, chatID)
 
-	url := auth.AuthURL(state)
-	return b.sendMessage(chatID, messages.AuthPrompt+url)
+	url := spotifyAuth().AuthURL(state,
+		oauth2.SetAuthURLParam("code_challenge", codeChallenge(verifier)),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+	return b.sendMessage(ctx, chatID, messages(ctx).AuthPrompt+url)
 }
 
-func (b *Bot) handleSetCity(chatID int64) error {
-	err := b.sendMessage(chatID, messages.EnterCity)
-	if err != nil {
+// handleSetCity просит ввести город. then команда, которую нужно выполнить после выбора города
+func (b *Bot) handleSetCity(ctx context.Context, chatID int64, then string) error {
+	if err := b.transition(ctx, chatID, StateWaitingForCity, pendingCommand{Then: then}); err != nil {
 		return err
 	}
 
-	b.storage.SaveChatState(chatID, StateWaitingForCity)
-	log.Printf("Set chat %d state to waiting for city", chatID)
-
-	return nil
+	return b.sendMessage(ctx, chatID, messages(ctx).EnterCity)
 }
 
-func (b *Bot) handleFavouriteArtists(chatID int64) error {
-	if !b.isAuthorized(chatID) {
-		return b.handleAuth(chatID)
-	}
-
-	names, err := b.getFavoriteArtistsNames(chatID)
+func (b *Bot) handleFavouriteArtists(ctx context.Context, chatID int64) error {
+	names, err := favoriteArtistsNames(ctx, b.storage, chatID)
 	if err != nil {
 		return fmt.Errorf("failed to get favorite artists for chat %d: %w", chatID, err)
 	}
 
 	if len(names) == 0 {
-		return b.sendMessage(chatID, messages.NoFavorites)
-	}
-
-	text := messages.FavoriteArtists
-	for i, name := range names {
-		text += fmt.Sprintf("%d. %s\n", i+1, name)
+		return b.sendMessage(ctx, chatID, messages(ctx).NoFavorites)
 	}
 
-	return b.sendMessage(chatID, text)
+	return b.sendTemplate(ctx, chatID, favoriteArtistsTemplate, favoriteArtistsData{Artists: names})
 }
 
-func (b *Bot) handleConcerts(chatID int64) error {
-	// тут пока предпологаем, что пользователь аутентифицирован и город установлен
-	city, err := b.storage.GetCity(chatID)
+func (b *Bot) handleConcerts(ctx context.Context, chatID int64) error {
+	city, err := b.storage.GetCity(ctx, chatID)
 	if err != nil {
 		return fmt.Errorf("failed to get city for chat %d: %w", chatID, err)
 	}
 
-	artists, err := b.getFavoriteArtistsNames(chatID)
+	artists, err := favoriteArtistsNames(ctx, b.storage, chatID)
 	if err != nil {
 		return fmt.Errorf("failed to get favorite artists for chat %d: %w", chatID, err)
 	}
 
 	if len(artists) == 0 {
-		return b.sendMessage(chatID, messages.NoFavorites)
+		return b.sendMessage(ctx, chatID, messages(ctx).NoFavorites)
 	}
 
-	err = b.sendMessage(chatID, messages.WaitForConcerts)
+	err = b.sendMessage(ctx, chatID, messages(ctx).WaitForConcerts)
 	if err != nil {
 		return err
 	}
 
-	concerts := b.concertsProvider.GetConcerts(artists, city)
+	concerts, err := b.concertsProvider.GetConcerts(ctx, artists, city.TimepadName)
+	if err != nil {
+		return fmt.Errorf("failed to get concerts for chat %d: %w", chatID, err)
+	}
 
 	if len(concerts) == 0 {
-		return b.sendMessage(chatID, messages.NoConcerts)
+		return b.sendMessage(ctx, chatID, messages(ctx).NoConcerts)
 	}
 
-	var sBuilder strings.Builder
-	sBuilder.WriteString(fmt.Sprintf("Найдено %d событий:\n\n", len(concerts)))
-	for _, c := range concerts {
-		sBuilder.WriteString(fmt.Sprintf("Название: %s\nВремя начала:%s\nСсылка: %s\n\n",
-			c.Name,
-			c.StartsAt,
-			c.URL))
+	m := messages(ctx)
+	err = b.sendMessage(ctx, chatID, m.Plural(m.ConcertsFound, len(concerts)))
+	if err != nil {
+		return err
 	}
 
-	return b.sendMessage(chatID, sBuilder.String())
-}
+	shown := concerts
+	if len(shown) > maxConcertCards {
+		shown = shown[:maxConcertCards]
+	}
 
-func (b *Bot) getFavoriteArtistsNames(chatID int64) ([]string, error) {
-	log.Printf("Getting top artists for chat ID: %d", chatID)
+	loc := cityLocation(city)
+	for _, c := range shown {
+		msg, err := newTemplateMessage(ctx, chatID, concertCardTemplate, newConcertCardData(c, loc))
+		if err != nil {
+			return err
+		}
+		msg.ReplyMarkup = concertKeyboard(m, c)
 
-	token, err := b.storage.GetToken(chatID)
-	if err != nil {
-		return []string{}, fmt.Errorf("failed to get token: %w", err)
+		if _, err := b.sender.Send(ctx, chatID, msg); err != nil {
+			return err
+		}
+	}
+
+	// остальные концерты есть в календаре
+	if len(concerts) > len(shown) {
+		return b.sendMessage(ctx, chatID, fmt.Sprintf(m.ConcertsTruncated, len(shown)))
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	return nil
+}
+
+func favoriteArtistsNames(ctx context.Context, storage Storage, chatID int64) ([]string, error) {
+	log.Printf("Getting top artists for chat ID: %d", chatID)
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	client := spotify.New(auth.Client(ctx, &token))
+	tokenSource := newTokenSource(ctx, storage, chatID)
+	if _, err := tokenSource.Token(); err != nil {
+		return []string{}, err
+	}
+
+	client := spotify.New(oauth2.NewClient(ctx, tokenSource))
 
 	artistsPage, err := client.CurrentUsersTopArtists(ctx, spotify.Limit(50))
 	if err != nil {
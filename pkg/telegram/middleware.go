@@ -0,0 +1,108 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+)
+
+// middleware оборачивает обработчик команды, cmd - команда, для которой строится цепочка
+type middleware func(cmd command, next commandHandler) commandHandler
+
+// commandMiddlewares цепочка вокруг каждой команды, от внешнего звена к внутреннему.
+// Проверки идут в порядке онбординга: сначала авторизация, потом город
+var commandMiddlewares = []middleware{
+	recoverPanic,
+	logCommand,
+	requireAdmin,
+	requireArgs,
+	requireAuth,
+	requireCity,
+}
+
+// chain оборачивает обработчик команды всеми commandMiddlewares
+func chain(cmd command, handle commandHandler) commandHandler {
+	for i := len(commandMiddlewares) - 1; i >= 0; i-- {
+		handle = commandMiddlewares[i](cmd, handle)
+	}
+	return handle
+}
+
+// recoverPanic превращает панику в обработчике в ошибку, чтобы она не роняла бота
+func recoverPanic(cmd command, next commandHandler) commandHandler {
+	return func(b *Bot, ctx context.Context, chatID int64, args string) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("[%s] Panic in /%s for chat %d: %v\n%s", correlationID(ctx), cmd.name, chatID, r, debug.Stack())
+				err = fmt.Errorf("panic in /%s: %v", cmd.name, r)
+			}
+		}()
+		return next(b, ctx, chatID, args)
+	}
+}
+
+func logCommand(cmd command, next commandHandler) commandHandler {
+	return func(b *Bot, ctx context.Context, chatID int64, args string) error {
+		start := time.Now()
+		err := next(b, ctx, chatID, args)
+
+		status := "ok"
+		if err != nil {
+			status = "failed"
+		}
+		log.Printf("[%s] Chat %d: /%s %s in %v",
+			correlationID(ctx), chatID, cmd.name, status, time.Since(start).Round(time.Millisecond))
+		return err
+	}
+}
+
+// requireAdmin для остальных чатов админской команды как будто нет
+func requireAdmin(cmd command, next commandHandler) commandHandler {
+	if cmd.requires&requiresAdmin == 0 {
+		return next
+	}
+	return func(b *Bot, ctx context.Context, chatID int64, args string) error {
+		if !isAdmin(chatID) {
+			return b.handleHelp(ctx, chatID)
+		}
+		return next(b, ctx, chatID, args)
+	}
+}
+
+// requireArgs без обязательных аргументов показывает, как вызвать команду
+func requireArgs(cmd command, next commandHandler) commandHandler {
+	return func(b *Bot, ctx context.Context, chatID int64, args string) error {
+		if cmd.missingArgs(args) {
+			return b.sendMessage(ctx, chatID, fmt.Sprintf(messages(ctx).CommandUsage, cmd.usage(messages(ctx))))
+		}
+		return next(b, ctx, chatID, args)
+	}
+}
+
+// requireAuth отправляет неавторизованный чат авторизоваться, команда выполнится после авторизации
+func requireAuth(cmd command, next commandHandler) commandHandler {
+	if cmd.requires&requiresAuth == 0 {
+		return next
+	}
+	return func(b *Bot, ctx context.Context, chatID int64, args string) error {
+		if !b.isAuthorized(ctx, chatID) {
+			return b.requestAuth(ctx, chatID, cmd.name)
+		}
+		return next(b, ctx, chatID, args)
+	}
+}
+
+// requireCity просит ввести город, команда выполнится сразу после его выбора
+func requireCity(cmd command, next commandHandler) commandHandler {
+	if cmd.requires&requiresCity == 0 {
+		return next
+	}
+	return func(b *Bot, ctx context.Context, chatID int64, args string) error {
+		if !b.isCitySet(ctx, chatID) {
+			return b.handleSetCity(ctx, chatID, cmd.name)
+		}
+		return next(b, ctx, chatID, args)
+	}
+}
@@ -0,0 +1,232 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shakareem/gigoseek/pkg/storage"
+)
+
+const (
+	remindCallback         = "remind"
+	cancelReminderCallback = "unremind"
+
+	reminderTimeFormat       = "02.01.2006 15:04"
+	reminderDispatchInterval = time.Minute
+
+	// после maxReminderAttempts неудачных отправок напоминание удаляется,
+	// пауза перед повтором удваивается начиная с reminderRetryDelay
+	maxReminderAttempts = 5
+	reminderRetryDelay  = time.Minute
+
+	// notificationRetention сколько отправленные напоминания хранятся в истории уведомлений для /export
+	notificationRetention       = 90 * 24 * time.Hour
+	notificationCleanupInterval = 24 * time.Hour
+)
+
+// reminderOffsets за сколько до начала концерта отправляются напоминания
+var reminderOffsets = []time.Duration{24 * time.Hour, 3 * time.Hour}
+
+func (b *Bot) handleRemind(ctx context.Context, chatID int64, concertID int) error {
+	concert, err := b.concertsProvider.GetConcert(ctx, concertID)
+	if err != nil {
+		return fmt.Errorf("failed to get concert %d: %w", concertID, err)
+	}
+
+	startsAt, err := concert.StartTime()
+	if err != nil {
+		return fmt.Errorf("failed to parse start time of concert %d: %w", concertID, err)
+	}
+	startsAt = startsAt.In(b.chatLocation(ctx, chatID))
+
+	scheduled := 0
+	now := time.Now()
+	for _, offset := range reminderOffsets {
+		remindAt := startsAt.Add(-offset)
+		if remindAt.Before(now) {
+			continue
+		}
+
+		err := b.storage.SaveReminder(ctx, storage.Reminder{
+			ChatID:      chatID,
+			ConcertID:   concert.ID,
+			ConcertName: concert.Name,
+			ConcertURL:  concert.URL,
+			StartsAt:    startsAt,
+			RemindAt:    remindAt,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to save reminder for chat %d: %w", chatID, err)
+		}
+		scheduled++
+	}
+
+	if scheduled == 0 {
+		return b.sendMessage(ctx, chatID, messages(ctx).ReminderTooLate)
+	}
+
+	log.Printf("Scheduled %d reminders about concert %d for chat %d", scheduled, concertID, chatID)
+	return b.sendMessage(ctx, chatID, messages(ctx).ReminderSet)
+}
+
+func (b *Bot) handleReminders(ctx context.Context, chatID int64) error {
+	reminders, err := b.storage.GetReminders(ctx, chatID)
+	if err != nil {
+		return fmt.Errorf("failed to get reminders for chat %d: %w", chatID, err)
+	}
+
+	if len(reminders) == 0 {
+		return b.sendMessage(ctx, chatID, messages(ctx).NoReminders)
+	}
+
+	loc := b.chatLocation(ctx, chatID)
+	var data remindersData
+	var rows [][]tgbotapi.InlineKeyboardButton
+	listed := map[int]bool{}
+	for _, r := range reminders {
+		if listed[r.ConcertID] {
+			continue
+		}
+		listed[r.ConcertID] = true
+
+		data.Reminders = append(data.Reminders, reminderData{Name: r.ConcertName, StartsAt: r.StartsAt.In(loc).Format(reminderTimeFormat)})
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("%s %d", messages(ctx).CancelReminder, len(listed)),
+				cancelReminderCallback+callbackSeparator+strconv.Itoa(r.ConcertID),
+			),
+		))
+	}
+
+	msg, err := newTemplateMessage(ctx, chatID, remindersTemplate, data)
+	if err != nil {
+		return err
+	}
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	_, err = b.sender.Send(ctx, chatID, msg)
+	return err
+}
+
+func (b *Bot) handleCancelReminder(ctx context.Context, chatID int64, concertID int) error {
+	err := b.storage.DeleteConcertReminders(ctx, chatID, concertID)
+	if err != nil {
+		return fmt.Errorf("failed to delete reminders for chat %d: %w", chatID, err)
+	}
+
+	log.Printf("Reminders about concert %d canceled for chat %d", concertID, chatID)
+	return b.sendMessage(ctx, chatID, messages(ctx).ReminderCanceled)
+}
+
+// runReminderDispatcher периодически отправляет наступившие напоминания.
+// Напоминания хранятся в базе, поэтому пропущенные за время простоя отправятся после перезапуска.
+// Отправленные напоминания старше notificationRetention удаляются раз в сутки.
+func (b *Bot) runReminderDispatcher(ctx context.Context) {
+	ticker := time.NewTicker(reminderDispatchInterval)
+	defer ticker.Stop()
+
+	cleanup := time.NewTicker(notificationCleanupInterval)
+	defer cleanup.Stop()
+
+	b.cleanupNotifications(ctx, time.Now())
+	for {
+		b.dispatchReminders(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-cleanup.C:
+			b.cleanupNotifications(ctx, now)
+		case <-ticker.C:
+		}
+	}
+}
+
+func (b *Bot) cleanupNotifications(ctx context.Context, now time.Time) {
+	deleted, err := b.storage.DeleteSentReminders(ctx, now.Add(-notificationRetention))
+	if err != nil {
+		log.Printf("Failed to delete old notifications: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("Deleted %d notifications older than %v", deleted, notificationRetention)
+	}
+}
+
+func (b *Bot) dispatchReminders(ctx context.Context, now time.Time) {
+	reminders, err := b.storage.GetDueReminders(ctx, now)
+	if err != nil {
+		log.Printf("Failed to get due reminders: %v", err)
+		return
+	}
+
+	for _, r := range reminders {
+		// если концерт уже начался, напоминание просто удаляется
+		if !r.StartsAt.After(now) {
+			if err := b.storage.DeleteReminder(ctx, r.ID); err != nil {
+				log.Printf("Failed to delete reminder %d: %v", r.ID, err)
+			}
+			continue
+		}
+
+		chatCtx := b.withChatLanguage(ctx, r.ChatID, "")
+		startsAt := r.StartsAt.In(b.chatLocation(ctx, r.ChatID)).Format(reminderTimeFormat)
+		data := reminderData{Name: r.ConcertName, StartsAt: startsAt, URL: r.ConcertURL}
+
+		if err := b.sendTemplate(chatCtx, r.ChatID, reminderTemplate, data); err != nil {
+			log.Printf("Failed to send reminder %d to chat %d (attempt %d): %v", r.ID, r.ChatID, r.Attempts+1, err)
+			b.retryReminder(ctx, r, now)
+			continue
+		}
+
+		// отправленные напоминания остаются в истории уведомлений для /export
+		if err := b.storage.MarkReminderSent(ctx, r.ID, now); err != nil {
+			log.Printf("Failed to mark reminder %d as sent: %v", r.ID, err)
+		}
+	}
+}
+
+// retryReminder откладывает неотправленное напоминание, а после maxReminderAttempts попыток удаляет его:
+// например, если пользователь заблокировал бота
+func (b *Bot) retryReminder(ctx context.Context, r storage.Reminder, now time.Time) {
+	if r.Attempts+1 >= maxReminderAttempts {
+		log.Printf("Giving up on reminder %d for chat %d after %d attempts", r.ID, r.ChatID, r.Attempts+1)
+		if err := b.storage.DeleteReminder(ctx, r.ID); err != nil {
+			log.Printf("Failed to delete reminder %d: %v", r.ID, err)
+		}
+		return
+	}
+
+	retryAt := now.Add(reminderRetryDelay << r.Attempts)
+	if err := b.storage.RetryReminder(ctx, r.ID, retryAt); err != nil {
+		log.Printf("Failed to postpone reminder %d: %v", r.ID, err)
+	}
+}
+
+// chatLocation часовой пояс города чата: в нём считаются и показываются напоминания
+func (b *Bot) chatLocation(ctx context.Context, chatID int64) *time.Location {
+	city, err := b.storage.GetCity(ctx, chatID)
+	if err != nil {
+		// город ещё не выбран
+		return cityLocation(storage.City{})
+	}
+	return cityLocation(city)
+}
+
+// cityLocation часовой пояс города, defaultTimezone если он не задан или неизвестен
+func cityLocation(city storage.City) *time.Location {
+	timezone := city.Timezone
+	if timezone == "" {
+		timezone = defaultTimezone
+	}
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		log.Printf("Unknown timezone %q of city %q: %v", timezone, city.Name, err)
+		return time.UTC
+	}
+	return loc
+}
@@ -0,0 +1,172 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// лимиты телеграма: не больше 30 сообщений в секунду всего и примерно 1 в секунду в один чат
+const (
+	globalSendRate  = 30
+	globalSendBurst = 30
+	chatSendRate    = 1
+	chatSendBurst   = 3
+
+	maxSendRetries = 3
+
+	// chatBucketTTL через сколько простоя лимитер чата удаляется
+	chatBucketTTL = 10 * time.Minute
+)
+
+// sender отправляет сообщения с учётом лимитов телеграма и повторяет запросы после 429
+type sender struct {
+	botAPI *tgbotapi.BotAPI
+	global *tokenBucket
+
+	mu        sync.Mutex
+	chats     map[int64]*tokenBucket
+	lastPrune time.Time
+}
+
+func newSender(botAPI *tgbotapi.BotAPI) *sender {
+	return &sender{
+		botAPI:    botAPI,
+		global:    newTokenBucket(globalSendRate, globalSendBurst),
+		chats:     make(map[int64]*tokenBucket),
+		lastPrune: time.Now(),
+	}
+}
+
+func (s *sender) Send(ctx context.Context, chatID int64, c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	var msg tgbotapi.Message
+	err := s.retry(ctx, chatID, func() error {
+		var err error
+		msg, err = s.botAPI.Send(c)
+		return err
+	})
+	return msg, err
+}
+
+// Request используется для запросов, которые не являются сообщениями в чат (например, ответы на callback),
+// поэтому ограничивается только общим лимитом
+func (s *sender) Request(ctx context.Context, c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	var resp *tgbotapi.APIResponse
+	err := s.retry(ctx, 0, func() error {
+		var err error
+		resp, err = s.botAPI.Request(c)
+		return err
+	})
+	return resp, err
+}
+
+func (s *sender) retry(ctx context.Context, chatID int64, send func() error) error {
+	for attempt := 0; ; attempt++ {
+		if err := s.wait(ctx, chatID); err != nil {
+			return err
+		}
+
+		err := send()
+
+		var tgErr *tgbotapi.Error
+		if !errors.As(err, &tgErr) || tgErr.RetryAfter == 0 || attempt >= maxSendRetries {
+			return err
+		}
+
+		retryAfter := time.Duration(tgErr.RetryAfter) * time.Second
+		log.Printf("Flood limit exceeded for chat %d, retrying in %v", chatID, retryAfter)
+		if err := sleep(ctx, retryAfter); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *sender) wait(ctx context.Context, chatID int64) error {
+	delay := s.global.reserve()
+	if chatID != 0 {
+		delay = max(delay, s.chatBucket(chatID).reserve())
+	}
+	return sleep(ctx, delay)
+}
+
+func (s *sender) chatBucket(chatID int64) *tokenBucket {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastPrune) > chatBucketTTL {
+		for id, bucket := range s.chats {
+			if bucket.idleSince(now) > chatBucketTTL {
+				delete(s.chats, id)
+			}
+		}
+		s.lastPrune = now
+	}
+
+	bucket, ok := s.chats[chatID]
+	if !ok {
+		bucket = newTokenBucket(chatSendRate, chatSendBurst)
+		s.chats[chatID] = bucket
+	}
+	return bucket
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type tokenBucket struct {
+	mu       sync.Mutex
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	return &tokenBucket{
+		rate:     rate,
+		capacity: burst,
+		tokens:   burst,
+		last:     time.Now(),
+	}
+}
+
+// reserve забирает токен и возвращает, сколько нужно подождать, пока он станет доступен
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens = min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) idleSince(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return now.Sub(b.last)
+}
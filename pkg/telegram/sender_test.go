@@ -0,0 +1,108 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// tooManyRequests ответ телеграма на превышение лимита
+func tooManyRequests(w http.ResponseWriter, retryAfter int) {
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(tgbotapi.APIResponse{
+		Ok:          false,
+		ErrorCode:   http.StatusTooManyRequests,
+		Description: "Too Many Requests: retry later",
+		Parameters:  &tgbotapi.ResponseParameters{RetryAfter: retryAfter},
+	})
+}
+
+func TestSenderRetriesAfterFloodLimit(t *testing.T) {
+	tg := newFakeTelegram(t)
+	limited := 1
+	tg.respond = func(w http.ResponseWriter, call fakeCall) bool {
+		if call.method != "sendMessage" || limited == 0 {
+			return false
+		}
+		limited--
+		tooManyRequests(w, 1)
+		return true
+	}
+	s := newSender(tg.botAPI(t))
+
+	start := time.Now()
+	if _, err := s.Send(t.Context(), 42, tgbotapi.NewMessage(42, "hi")); err != nil {
+		t.Fatalf("Send after 429: %v", err)
+	}
+
+	if calls := len(tg.callsOf("sendMessage")); calls != 2 {
+		t.Errorf("sendMessage called %d times, want a retry after 429", calls)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retry came after %v, want retry_after of 1s", elapsed)
+	}
+}
+
+func TestSenderDoesNotRetryOtherErrors(t *testing.T) {
+	tg := newFakeTelegram(t)
+	tg.respond = func(w http.ResponseWriter, call fakeCall) bool {
+		if call.method != "sendMessage" {
+			return false
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: false, ErrorCode: http.StatusBadRequest, Description: "Bad Request: chat not found"})
+		return true
+	}
+	s := newSender(tg.botAPI(t))
+
+	_, err := s.Send(t.Context(), 42, tgbotapi.NewMessage(42, "hi"))
+	var tgErr *tgbotapi.Error
+	if !errors.As(err, &tgErr) || tgErr.Code != http.StatusBadRequest {
+		t.Errorf("Send error = %v, want the 400 from Telegram", err)
+	}
+	if calls := len(tg.callsOf("sendMessage")); calls != 1 {
+		t.Errorf("sendMessage called %d times, want no retries", calls)
+	}
+}
+
+func TestSenderGivesUpOnCanceledContext(t *testing.T) {
+	tg := newFakeTelegram(t)
+	tg.respond = func(w http.ResponseWriter, call fakeCall) bool {
+		if call.method != "sendMessage" {
+			return false
+		}
+		tooManyRequests(w, 30)
+		return true
+	}
+	s := newSender(tg.botAPI(t))
+
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := s.Send(ctx, 42, tgbotapi.NewMessage(42, "hi")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Send error = %v, want to stop waiting retry_after when ctx is done", err)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(chatSendRate, chatSendBurst)
+
+	for i := range chatSendBurst {
+		if delay := b.reserve(); delay != 0 {
+			t.Fatalf("message %d of the burst delayed by %v", i, delay)
+		}
+	}
+
+	// следующий токен появится через 1/rate секунды, и каждый следующий ещё позже
+	first, second := b.reserve(), b.reserve()
+	if first <= 900*time.Millisecond || first > time.Second {
+		t.Errorf("delay after burst = %v, want about 1s", first)
+	}
+	if second <= first {
+		t.Errorf("delays %v then %v, want the queue to grow", first, second)
+	}
+}
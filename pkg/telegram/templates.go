@@ -0,0 +1,209 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shakareem/gigoseek/pkg/concerts"
+	"github.com/shakareem/gigoseek/pkg/config"
+	"github.com/shakareem/gigoseek/pkg/i18n"
+)
+
+// Имена шаблонов сообщений. Шаблоны лежат в Templates каталогов и переопределяются из templates в конфиге
+const (
+	concertCardTemplate     = "concert_card"
+	favoriteArtistsTemplate = "favorite_artists"
+	helpTemplate            = "help"
+	remindersTemplate       = "reminders"
+	reminderTemplate        = "reminder"
+)
+
+var templateNames = []string{
+	concertCardTemplate,
+	favoriteArtistsTemplate,
+	helpTemplate,
+	remindersTemplate,
+	reminderTemplate,
+}
+
+// Данные шаблонов
+type (
+	concertCardData struct {
+		Name     string
+		StartsAt string
+		Location string
+		URL      string
+	}
+
+	favoriteArtistsData struct {
+		Artists []string
+	}
+
+	helpData struct {
+		Commands []helpItem
+	}
+
+	helpItem struct {
+		Usage       string
+		Description string
+	}
+
+	remindersData struct {
+		Reminders []reminderData
+	}
+
+	reminderData struct {
+		Name     string
+		StartsAt string
+		URL      string
+	}
+)
+
+// newConcertCardData время начала показывается в часовом поясе города пользователя
+func newConcertCardData(c concerts.Concert, loc *time.Location) concertCardData {
+	startsAt := c.StartsAt
+	if t, err := c.StartTime(); err == nil {
+		startsAt = t.In(loc).Format(reminderTimeFormat)
+	}
+	return concertCardData{Name: c.Name, StartsAt: startsAt, Location: c.Location(), URL: c.URL}
+}
+
+var templateFuncs = template.FuncMap{
+	// escape подставляется в каждое действие шаблона автоматически
+	"escape": func(v any) string { return html.EscapeString(fmt.Sprint(v)) },
+	// raw отключает экранирование: {{raw .Text}}, только для заведомо безопасной разметки
+	"raw": func(v any) string { return fmt.Sprint(v) },
+	// inc нумерация с единицы в range
+	"inc": func(i int) int { return i + 1 },
+}
+
+// messageTemplates разбираются при первом сообщении: переопределения берутся из конфига
+var messageTemplates = sync.OnceValue(func() map[i18n.Lang]*template.Template {
+	return mustParseTemplates(config.Get().Templates)
+})
+
+func mustParseTemplates(overrides map[string]map[string]string) map[i18n.Lang]*template.Template {
+	templates, err := parseTemplates(overrides)
+	if err != nil {
+		log.Fatalf("Failed to parse message templates: %v", err)
+	}
+	return templates
+}
+
+// parseTemplates собирает шаблоны каждого языка из каталога и переопределений из конфига
+func parseTemplates(overrides map[string]map[string]string) (map[i18n.Lang]*template.Template, error) {
+	for code, langOverrides := range overrides {
+		if _, ok := i18n.Parse(code); !ok {
+			return nil, fmt.Errorf("templates for unsupported language %q", code)
+		}
+		for name := range langOverrides {
+			if !slices.Contains(templateNames, name) {
+				return nil, fmt.Errorf("unknown template %q, known: %s", name, strings.Join(templateNames, ", "))
+			}
+		}
+	}
+
+	templates := make(map[i18n.Lang]*template.Template, len(i18n.Languages))
+	for _, lang := range i18n.Languages {
+		sources := maps.Clone(i18n.Get(lang).Templates)
+		maps.Copy(sources, overrides[string(lang)])
+
+		set := template.New(string(lang)).Funcs(templateFuncs).Option("missingkey=error")
+		for _, name := range templateNames {
+			source, ok := sources[name]
+			if !ok {
+				return nil, fmt.Errorf("no %s template %q", lang, name)
+			}
+			t, err := set.New(name).Parse(source)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s template %q: %w", lang, name, err)
+			}
+			autoescape(t.Tree.Root)
+		}
+		templates[lang] = set
+	}
+
+	return templates, nil
+}
+
+// autoescape дописывает escape в конец каждого действия, которое что-то выводит,
+// так же как это делает html/template. Действия с raw и присваивания не трогаются
+func autoescape(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			autoescape(child)
+		}
+	case *parse.ActionNode:
+		escapePipe(n.Pipe)
+	case *parse.IfNode:
+		autoescape(n.List)
+		autoescape(n.ElseList)
+	case *parse.RangeNode:
+		autoescape(n.List)
+		autoescape(n.ElseList)
+	case *parse.WithNode:
+		autoescape(n.List)
+		autoescape(n.ElseList)
+	}
+}
+
+func escapePipe(pipe *parse.PipeNode) {
+	if len(pipe.Decl) > 0 || len(pipe.Cmds) == 0 {
+		return
+	}
+
+	last := pipe.Cmds[len(pipe.Cmds)-1]
+	if ident, ok := last.Args[0].(*parse.IdentifierNode); ok && (ident.Ident == "raw" || ident.Ident == "escape") {
+		return
+	}
+
+	pipe.Cmds = append(pipe.Cmds, &parse.CommandNode{
+		NodeType: parse.NodeCommand,
+		Args:     []parse.Node{parse.NewIdentifier("escape").SetTree(nil).SetPos(pipe.Position())},
+	})
+}
+
+// render выполняет шаблон на языке чата. Результат - текст в HTML-разметке Telegram
+func render(ctx context.Context, name string, data any) (string, error) {
+	var sBuilder strings.Builder
+	if err := messageTemplates()[messages(ctx).Lang].ExecuteTemplate(&sBuilder, name, data); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %w", name, err)
+	}
+	return sBuilder.String(), nil
+}
+
+// newTemplateMessage сообщение из шаблона с HTML-разметкой, к нему ещё можно добавить кнопки
+func newTemplateMessage(ctx context.Context, chatID int64, name string, data any) (tgbotapi.MessageConfig, error) {
+	text, err := render(ctx, name, data)
+	if err != nil {
+		return tgbotapi.MessageConfig{}, err
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.DisableWebPagePreview = true
+	return msg, nil
+}
+
+func (b *Bot) sendTemplate(ctx context.Context, chatID int64, name string, data any) error {
+	msg, err := newTemplateMessage(ctx, chatID, name, data)
+	if err != nil {
+		return err
+	}
+	_, err = b.sender.Send(ctx, chatID, msg)
+	return err
+}
@@ -0,0 +1,64 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shakareem/gigoseek/pkg/i18n"
+)
+
+func TestRenderEscapes(t *testing.T) {
+	ctx := withLanguage(t.Context(), i18n.English)
+
+	text, err := render(ctx, favoriteArtistsTemplate, favoriteArtistsData{Artists: []string{"Tom & Jerry", "<b>Bold</b>"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "<b>Your favorite artists:</b>\n1. Tom &amp; Jerry\n2. &lt;b&gt;Bold&lt;/b&gt;\n"
+	if text != want {
+		t.Errorf("render = %q, want %q", text, want)
+	}
+
+	text, err = render(ctx, concertCardTemplate, concertCardData{
+		Name:     "Rock & Roll",
+		StartsAt: "01.06.2026 19:00",
+		URL:      `https://afisha.timepad.ru/event/1?a=1&b="2"`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text, "<b>Rock &amp; Roll</b>") ||
+		!strings.Contains(text, `href="https://afisha.timepad.ru/event/1?a=1&amp;b=&#34;2&#34;"`) ||
+		strings.Contains(text, "📍") {
+		t.Errorf("render concert card = %q", text)
+	}
+}
+
+func TestParseTemplatesOverrides(t *testing.T) {
+	templates, err := parseTemplates(map[string]map[string]string{
+		"en": {helpTemplate: `{{range .Commands}}{{.Usage}} {{raw .Description}};{{end}}`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sBuilder strings.Builder
+	data := helpData{Commands: []helpItem{{Usage: "/addcity <name>", Description: "<i>admin</i>"}}}
+	if err := templates[i18n.English].ExecuteTemplate(&sBuilder, helpTemplate, data); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sBuilder.String(), "/addcity &lt;name&gt; <i>admin</i>;"; got != want {
+		t.Errorf("overridden template = %q, want %q", got, want)
+	}
+
+	invalid := []map[string]map[string]string{
+		{"de": {helpTemplate: "help"}},
+		{"en": {"unknown": "text"}},
+		{"en": {helpTemplate: "{{.Commands"}},
+	}
+	for _, overrides := range invalid {
+		if _, err := parseTemplates(overrides); err == nil {
+			t.Errorf("parseTemplates(%v) succeeded", overrides)
+		}
+	}
+}
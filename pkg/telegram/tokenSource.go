@@ -0,0 +1,255 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// invalidGrantError spotify отвечает так, если refresh token отозван или устарел
+	invalidGrantError = "invalid_grant"
+
+	tokenRefreshInterval = 5 * time.Minute
+	// tokenRefreshAhead за сколько до истечения токены обновляются в фоне
+	tokenRefreshAhead = 15 * time.Minute
+	// tokenRefreshActiveWithin в фоне обновляются токены только тех, кто писал боту за это время,
+	// остальные обновятся при следующем запросе
+	tokenRefreshActiveWithin = 30 * 24 * time.Hour
+)
+
+// errReauthRequired spotify больше не принимает refresh token, нужна повторная авторизация
+var errReauthRequired = errors.New("spotify authorization revoked, re-auth required")
+
+// storageTokenSource отдаёт токен чата и сохраняет в хранилище каждый обновлённый токен.
+// Его можно передавать в oauth2.NewClient: в отличие от spotifyAuth().Client, обновлённый токен не потеряется
+type storageTokenSource struct {
+	ctx     context.Context
+	storage Storage
+	chatID  int64
+
+	mu    sync.Mutex
+	token *oauth2.Token
+}
+
+func newTokenSource(ctx context.Context, storage Storage, chatID int64) *storageTokenSource {
+	return &storageTokenSource{ctx: ctx, storage: storage, chatID: chatID}
+}
+
+func (s *storageTokenSource) Token() (*oauth2.Token, error) {
+	return s.tokenValidFor(0)
+}
+
+// tokenValidFor возвращает токен, который будет действителен ещё хотя бы ttl, при необходимости обновляя его
+func (s *storageTokenSource) tokenValidFor(ttl time.Duration) (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == nil {
+		token, err := s.storage.GetToken(s.ctx, s.chatID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get token for chat %d: %w", s.chatID, err)
+		}
+		s.token = &token
+	}
+
+	if validFor(s.token, ttl) {
+		return s.token, nil
+	}
+
+	return s.refresh(ttl)
+}
+
+func validFor(token *oauth2.Token, ttl time.Duration) bool {
+	return token.Valid() && (token.Expiry.IsZero() || time.Until(token.Expiry) > ttl)
+}
+
+// refresh обновляет токен. Запрос к spotify идёт вне транзакции, чтобы не держать блокировку
+// строки или всей базы на время сетевого запроса. Внутри процесса обновления одного чата идут
+// по очереди, а между экземплярами бота сохраняется только тот результат, что получен из
+// токена, который всё ещё лежит в хранилище
+func (s *storageTokenSource) refresh(ttl time.Duration) (*oauth2.Token, error) {
+	unlock := tokenRefreshLocks.lock(s.chatID)
+	defer unlock()
+
+	current, err := s.storage.GetToken(s.ctx, s.chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token for chat %d: %w", s.chatID, err)
+	}
+	// токен мог обновить другой обработчик, пока мы ждали своей очереди
+	if validFor(&current, ttl) {
+		s.token = &current
+		return s.token, nil
+	}
+
+	newToken, refreshErr := spotifyAuth().RefreshToken(s.ctx, &current)
+	if refreshErr != nil && !isInvalidGrant(refreshErr) {
+		return nil, fmt.Errorf("failed to refresh access token for chat %d: %w", s.chatID, refreshErr)
+	}
+	// без этой проверки невалидный токен от spotify обновлялся бы по кругу
+	if refreshErr == nil && !newToken.Valid() {
+		return nil, fmt.Errorf("spotify returned invalid token for chat %d", s.chatID)
+	}
+
+	var revoked bool
+	err = s.storage.WithTx(s.ctx, func(tx Storage) error {
+		stored, err := tx.GetToken(s.ctx, s.chatID)
+		if err != nil {
+			return fmt.Errorf("failed to get token for chat %d: %w", s.chatID, err)
+		}
+		// пока шёл запрос к spotify, токен обновил другой экземпляр бота: берём его результат,
+		// а отказ spotify в этом случае относится к уже потраченному refresh token
+		if !sameToken(stored, current) {
+			s.token = &stored
+			return nil
+		}
+
+		if refreshErr != nil {
+			revoked = true
+			return revoke(s.ctx, tx, s.chatID)
+		}
+
+		if err := tx.SaveToken(s.ctx, s.chatID, *newToken); err != nil {
+			return fmt.Errorf("failed to save refreshed token for chat %d: %w", s.chatID, err)
+		}
+		s.token = newToken
+
+		log.Printf("Token for chat %d refreshed successfully", s.chatID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, errReauthRequired
+	}
+
+	return s.token, nil
+}
+
+func sameToken(a, b oauth2.Token) bool {
+	return a.AccessToken == b.AccessToken && a.RefreshToken == b.RefreshToken
+}
+
+// tokenRefreshLocks очередь обновлений токена по чатам: фоновое обновление и запрос пользователя
+// в одном процессе не должны потратить один refresh token дважды
+var tokenRefreshLocks = chatLocks{locks: map[int64]*chatLock{}}
+
+type chatLocks struct {
+	mu    sync.Mutex
+	locks map[int64]*chatLock
+}
+
+type chatLock struct {
+	mu      sync.Mutex
+	waiters int
+}
+
+// lock захватывает блокировку чата; запись удаляется, когда её больше никто не ждёт
+func (l *chatLocks) lock(chatID int64) (unlock func()) {
+	l.mu.Lock()
+	lock, ok := l.locks[chatID]
+	if !ok {
+		lock = &chatLock{}
+		l.locks[chatID] = lock
+	}
+	lock.waiters++
+	l.mu.Unlock()
+
+	lock.mu.Lock()
+
+	return func() {
+		lock.mu.Unlock()
+
+		l.mu.Lock()
+		lock.waiters--
+		if lock.waiters == 0 {
+			delete(l.locks, chatID)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// revoke удаляет отозванный токен и переводит чат в ожидание авторизации
+func revoke(ctx context.Context, tx Storage, chatID int64) error {
+	log.Printf("Spotify refresh token of chat %d is revoked", chatID)
+
+	if err := tx.DeleteToken(ctx, chatID); err != nil {
+		return fmt.Errorf("failed to delete revoked token of chat %d: %w", chatID, err)
+	}
+	if err := transition(ctx, tx, chatID, StateWaitingForAuth, nil); err != nil {
+		return fmt.Errorf("failed to save state of chat %d: %w", chatID, err)
+	}
+	return nil
+}
+
+func isInvalidGrant(err error) bool {
+	var retrieveErr *oauth2.RetrieveError
+	if !errors.As(err, &retrieveErr) {
+		return false
+	}
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(retrieveErr.Body, &body); err != nil {
+		return false
+	}
+
+	return body.Error == invalidGrantError
+}
+
+// promptReauth сообщает, что доступ к spotify потерян, и начинает авторизацию заново
+func (b *Bot) promptReauth(ctx context.Context, chatID int64) error {
+	if err := b.sendMessage(ctx, chatID, messages(ctx).ReauthRequired); err != nil {
+		return err
+	}
+	return b.requestAuth(ctx, chatID, "")
+}
+
+// runTokenRefresher заранее обновляет токены недавно активных чатов, которые скоро истекут,
+// чтобы запросы пользователей не ждали обновления и отзыв доступа обнаруживался сразу
+func (b *Bot) runTokenRefresher(ctx context.Context) {
+	ticker := time.NewTicker(tokenRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		b.refreshExpiringTokens(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (b *Bot) refreshExpiringTokens(ctx context.Context, now time.Time) {
+	chatIDs, err := b.storage.GetExpiringTokens(ctx, now.Add(tokenRefreshAhead), now.Add(-tokenRefreshActiveWithin))
+	if err != nil {
+		log.Printf("Failed to get expiring tokens: %v", err)
+		return
+	}
+
+	for _, chatID := range chatIDs {
+		if ctx.Err() != nil {
+			return
+		}
+
+		_, err := newTokenSource(ctx, b.storage, chatID).tokenValidFor(tokenRefreshAhead)
+		switch {
+		case errors.Is(err, errReauthRequired):
+			if err := b.promptReauth(b.withChatLanguage(ctx, chatID, ""), chatID); err != nil {
+				log.Printf("Failed to prompt chat %d to re-auth: %v", chatID, err)
+			}
+		case err != nil:
+			log.Printf("Failed to refresh token in background: %v", err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package telegram
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestChatLocksSerializeChat(t *testing.T) {
+	locks := chatLocks{locks: map[int64]*chatLock{}}
+
+	var wg sync.WaitGroup
+	var inside, maxInside int
+	var mu sync.Mutex
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := locks.lock(42)
+			defer unlock()
+
+			mu.Lock()
+			inside++
+			maxInside = max(maxInside, inside)
+			mu.Unlock()
+
+			mu.Lock()
+			inside--
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if maxInside != 1 {
+		t.Errorf("%d refreshes of one chat ran at once", maxInside)
+	}
+	if len(locks.locks) != 0 {
+		t.Errorf("%d locks left after all refreshes finished", len(locks.locks))
+	}
+}
+
+func TestChatLocksIndependentChats(t *testing.T) {
+	locks := chatLocks{locks: map[int64]*chatLock{}}
+
+	unlock := locks.lock(1)
+	defer unlock()
+
+	// блокировка другого чата не должна ждать первую
+	done := make(chan struct{})
+	go func() {
+		locks.lock(2)()
+		close(done)
+	}()
+	<-done
+}
@@ -0,0 +1,83 @@
+package telegram
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shakareem/gigoseek/pkg/config"
+)
+
+const (
+	UpdatesModePolling = "polling"
+	UpdatesModeWebhook = "webhook"
+
+	webhookSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+)
+
+var allowedUpdates = []string{"message", "callback_query"}
+
+// WebhookHandler принимает обновления от телеграма, его нужно повесить на путь из конфига
+func (b *Bot) WebhookHandler() http.Handler {
+	return http.HandlerFunc(b.handleWebhook)
+}
+
+func (b *Bot) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	secret := r.Header.Get(webhookSecretHeader)
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(config.Get().TelegramWebhookSecret)) != 1 {
+		log.Printf("Webhook request with invalid secret token from %s", r.RemoteAddr)
+		http.Error(w, "Invalid secret token", http.StatusUnauthorized)
+		return
+	}
+
+	update, err := b.botAPI.HandleUpdate(r)
+	if err != nil {
+		log.Printf("Failed to parse webhook update: %v", err)
+		http.Error(w, "Invalid update", http.StatusBadRequest)
+		return
+	}
+
+	// на 503 телеграм пришлёт обновление ещё раз, так что при остановке оно не потеряется
+	select {
+	case b.webhookUpdates <- *update:
+	case <-b.webhookStopped:
+		http.Error(w, "Bot is stopping", http.StatusServiceUnavailable)
+	case <-r.Context().Done():
+		http.Error(w, "Update not accepted", http.StatusServiceUnavailable)
+	}
+}
+
+func (b *Bot) setWebhook() error {
+	cfg := config.Get()
+
+	allowed, err := json.Marshal(allowedUpdates)
+	if err != nil {
+		return err
+	}
+
+	params := tgbotapi.Params{
+		"url":             cfg.Telegram.WebhookURL,
+		"secret_token":    cfg.TelegramWebhookSecret,
+		"allowed_updates": string(allowed),
+	}
+
+	// в v5.5.1 WebhookConfig не умеет secret_token, поэтому запрос собираем вручную
+	if _, err := b.botAPI.MakeRequest("setWebhook", params); err != nil {
+		return fmt.Errorf("failed to set webhook: %w", err)
+	}
+
+	log.Printf("Webhook set to %s", cfg.Telegram.WebhookURL)
+	return nil
+}
+
+func (b *Bot) deleteWebhook() error {
+	if _, err := b.botAPI.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	log.Println("Webhook deleted")
+	return nil
+}
@@ -0,0 +1,50 @@
+package telegram
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shakareem/gigoseek/pkg/config"
+	"github.com/shakareem/gigoseek/pkg/storage"
+)
+
+const webhookUpdate = `{"update_id": 7, "message": {"message_id": 1, "date": 0, "chat": {"id": 42, "type": "private"}, "text": "/help"}}`
+
+func postUpdate(b *Bot) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(webhookUpdate))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set(webhookSecretHeader, config.Get().TelegramWebhookSecret)
+
+	w := httptest.NewRecorder()
+	b.handleWebhook(w, r)
+	return w
+}
+
+func TestWebhookAcceptsUpdateOnlyWhenReceived(t *testing.T) {
+	b := NewBot(newFakeTelegram(t).botAPI(t), storage.NewInMemoryStorage(), nil, nil)
+
+	received := make(chan int)
+	go func() {
+		update := <-b.webhookUpdates
+		received <- update.UpdateID
+	}()
+
+	if w := postUpdate(b); w.Code != http.StatusOK {
+		t.Fatalf("webhook replied %d, want 200", w.Code)
+	}
+	if id := <-received; id != 7 {
+		t.Errorf("received update %d, want 7", id)
+	}
+}
+
+func TestWebhookRejectsUpdatesAfterStop(t *testing.T) {
+	b := NewBot(newFakeTelegram(t).botAPI(t), storage.NewInMemoryStorage(), nil, nil)
+	close(b.webhookStopped)
+
+	// 200 без обработки потеряло бы обновление, на 503 телеграм повторит его
+	if w := postUpdate(b); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("webhook replied %d after stop, want 503", w.Code)
+	}
+}